@@ -5,7 +5,6 @@ go 1.17
 require (
 	github.com/fortytw2/leaktest v1.3.0
 	github.com/wader/osleaktest v0.0.0-20191111175233-f643b0fed071
+	golang.org/x/sys v0.1.0
 	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
 )
-
-require golang.org/x/sys v0.1.0 // indirect
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wader/ffcat/internal/display"
+	"github.com/wader/ffcat/internal/goffmpeg"
+	"github.com/wader/ffcat/internal/kitty"
+	"github.com/wader/ffcat/internal/render"
+	"github.com/wader/ffcat/internal/render/ffmpeg"
+	"golang.org/x/term"
+)
+
+const interactiveHelp = "←/→ frame  ↑/↓ 1s  PgUp/PgDn 10s  0-9 jump  a audio  q quit"
+
+// duration of audio shown around current position
+const interactiveAudioDuration = 1.0
+
+type key int
+
+const (
+	keyUnknown key = iota
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyQuit
+	keyAudio
+	keyDigit
+)
+
+func parseKey(bs []byte) (key, int) {
+	switch s := string(bs); s {
+	case "\x1b[D", "\x1bOD", "h":
+		return keyLeft, 0
+	case "\x1b[C", "\x1bOC", "l":
+		return keyRight, 0
+	case "\x1b[A", "\x1bOA", "k":
+		return keyUp, 0
+	case "\x1b[B", "\x1bOB", "j":
+		return keyDown, 0
+	case "\x1b[5~":
+		return keyPageUp, 0
+	case "\x1b[6~":
+		return keyPageDown, 0
+	// esc, ctrl-c, ctrl-d
+	case "q", "\x1b", "\x03", "\x04":
+		return keyQuit, 0
+	case "a":
+		return keyAudio, 0
+	default:
+		if len(s) == 1 && s[0] >= '0' && s[0] <= '9' {
+			return keyDigit, int(s[0] - '0')
+		}
+	}
+	return keyUnknown, 0
+}
+
+func formatTimestamp(t float64) string {
+	h := int(t / 3600)
+	m := int(t/60) % 60
+	s := t - float64(h*3600+m*60)
+	return fmt.Sprintf("%d:%02d:%06.3f", h, m, s)
+}
+
+// crlfWriter translates newlines as output processing is off in raw mode
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// interactive shows one frame at a time and lets user step using keys
+func interactive(ctx context.Context, d display.Display, termRes display.Resolution, path string) error {
+	fp := goffmpeg.FFProbeCmd{Context: ctx, Input: goffmpeg.Input{File: path}}
+	if err := fp.Run(); err != nil {
+		return err
+	}
+	pr := fp.ProbeResult
+	vs, ok := pr.FindFirstStreamCodecType("video")
+	if !ok {
+		return fmt.Errorf("%s: no video stream", path)
+	}
+	duration := pr.Duration().Seconds()
+
+	// ffmpeg renderer seeks using path, reader is not used
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	in := render.Input{Name: path, Path: path, Reader: f}
+
+	fps := vs.FrameRate()
+	frameDuration := 1 / 25.0
+	if fps > 0 {
+		frameDuration = 1 / fps
+	}
+	tr, err := rangeFlag.r.Resolve(duration, fps)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	oldState, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return err
+	}
+
+	// use alternate screen to not add to scrollback
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	pos := tr.Offset
+	defer func() {
+		fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+		term.Restore(int(tty.Fd()), oldState)
+		fmt.Println(formatTimestamp(pos))
+	}()
+
+	// leave room for status line
+	rRes := render.Resolution(termRes)
+	rRes.Height -= rRes.HeightAlign * 2
+	showAudio := false
+
+	// only delete our own images on redraw to keep other images, each image
+	// in a frame needs its own id as sending an image with the same id
+	// replaces it
+	kd, isKitty := d.(kitty.Display)
+	kittyBaseID := kitty.NewID()
+	kittyImages := 0
+
+	b := make([]byte, 16)
+	for {
+		if pos < 0 {
+			pos = 0
+		}
+		if duration > 0 && pos > duration-frameDuration {
+			pos = duration - frameDuration
+		}
+
+		// in alternate screen so clear screen only, not scrollback
+		if _, err := os.Stdout.Write([]byte("\x1b[H\x1b[2J")); err != nil {
+			return err
+		}
+		if isKitty {
+			for i := 0; i < kittyImages; i++ {
+				kd.ID = kittyBaseID + uint32(i)
+				if err := kd.Delete(os.Stdout); err != nil {
+					return err
+				}
+			}
+			kittyImages = 0
+		}
+
+		// audio toggle shows streams selected by -s or all
+		streams := "v"
+		if showAudio {
+			streams = *streamsFlag
+		}
+
+		// cancelled per frame so that ffmpeg stops if display fails
+		frameCtx, frameCancel := context.WithCancel(ctx)
+		o, err := ffmpeg.Render{}.Output(frameCtx, in, rRes, render.Range{
+			Offset:   render.Seconds(pos),
+			Duration: render.Seconds(interactiveAudioDuration),
+			Delta:    render.Seconds(interactiveAudioDuration),
+		}, render.Options{
+			Streams: streams,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "%s\r\n", err)
+		} else {
+			for im := range o.Images() {
+				if p, ok := im.(render.Partial); ok && p.Partial() {
+					continue
+				}
+				if isKitty {
+					kd.ID = kittyBaseID + uint32(kittyImages)
+					kittyImages++
+					d = kd
+				}
+				if err := d.Image(crlfWriter{w: os.Stdout}, im.Image(), termRes); err != nil {
+					frameCancel()
+					return err
+				}
+				fmt.Fprint(os.Stdout, "\r\n")
+			}
+			if err := o.Err(); err != nil {
+				fmt.Fprintf(os.Stdout, "%s\r\n", err)
+			}
+		}
+		frameCancel()
+		fmt.Fprintf(os.Stdout, "%s / %s  %s", formatTimestamp(pos), formatTimestamp(duration), interactiveHelp)
+
+		n, err := tty.Read(b)
+		if err != nil {
+			return err
+		}
+		k, digit := parseKey(b[0:n])
+		switch k {
+		case keyLeft:
+			pos -= frameDuration
+		case keyRight:
+			pos += frameDuration
+		case keyUp:
+			pos += 1
+		case keyDown:
+			pos -= 1
+		case keyPageUp:
+			pos += 10
+		case keyPageDown:
+			pos -= 10
+		case keyDigit:
+			pos = duration * float64(digit) / 10
+		case keyAudio:
+			showAudio = !showAudio
+		case keyQuit:
+			return nil
+		}
+	}
+}
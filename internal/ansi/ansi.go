@@ -0,0 +1,195 @@
+package ansi
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/wader/ffcat/internal/display"
+	"golang.org/x/term"
+)
+
+// Mode is color mode to use
+type Mode int
+
+const (
+	TrueColor Mode = iota
+	Color256
+	Color16
+)
+
+// Size of a cell in virtual pixels, see display.TextCellWidth. Each cell
+// shows two pixels using upper half block.
+const (
+	CellWidth  = display.TextCellWidth
+	CellHeight = display.TextCellHeight
+)
+
+const upperHalfBlock = "▀"
+const lowerHalfBlock = "▄"
+
+// IsTrueColor checks if terminal says it supports 24-bit colors
+func IsTrueColor() bool {
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return true
+	}
+	return false
+}
+
+func PixelResolution(f *os.File) (display.Resolution, error) {
+	return display.TextPixelResolution(f)
+}
+
+type cellColor struct {
+	c           color.RGBA
+	transparent bool
+}
+
+// sample averages a block of pixels, mostly transparent blocks are transparent
+func sample(m image.Image, r image.Rectangle) cellColor {
+	r = r.Intersect(m.Bounds())
+	if r.Empty() {
+		return cellColor{transparent: true}
+	}
+	var sr, sg, sb, sa, n uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			// premultiplied so transparent pixels don't contribute color
+			cr, cg, cb, ca := m.At(x, y).RGBA()
+			sr += uint64(cr)
+			sg += uint64(cg)
+			sb += uint64(cb)
+			sa += uint64(ca)
+			n++
+		}
+	}
+	if sa/n < 0x8000 {
+		return cellColor{transparent: true}
+	}
+	return cellColor{c: color.RGBA{
+		R: uint8(sr * 0xff / sa),
+		G: uint8(sg * 0xff / sa),
+		B: uint8(sb * 0xff / sa),
+		A: 0xff,
+	}}
+}
+
+func (m Mode) sgr(fg bool, c color.RGBA) string {
+	base := 38
+	if !fg {
+		base = 48
+	}
+	switch m {
+	case Color256:
+		// skip system colors, they depend on terminal theme
+		i := 16 + nearest(palette256[16:], c)
+		return "\x1b[" + strconv.Itoa(base) + ";5;" + strconv.Itoa(i) + "m"
+	case Color16:
+		i := nearest(palette16, c)
+		// 30-37 and 90-97 for foreground, +10 for background
+		code := 30 + i
+		if i >= 8 {
+			code = 90 + i - 8
+		}
+		if !fg {
+			code += 10
+		}
+		return "\x1b[" + strconv.Itoa(code) + "m"
+	default:
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", base, c.R, c.G, c.B)
+	}
+}
+
+// Image writes m using half block characters and SGR colors. Image is
+// downscaled to fit columns cells wide, zero columns means no limit.
+func Image(w io.Writer, m image.Image, mode Mode, columns int) error {
+	b := m.Bounds()
+
+	// size of pixel block that each half cell represents
+	bw := CellWidth
+	bh := CellHeight / 2
+	if columns > 0 && (b.Dx()+bw-1)/bw > columns {
+		bw = (b.Dx() + columns - 1) / columns
+		bh = bw * CellHeight / CellWidth / 2
+	}
+
+	cols := (b.Dx() + bw - 1) / bw
+	rows := (b.Dy() + bh*2 - 1) / (bh * 2)
+
+	bwr := bufio.NewWriter(w)
+	for row := 0; row < rows; row++ {
+		prevFG, prevBG := "", ""
+		for col := 0; col < cols; col++ {
+			x := b.Min.X + col*bw
+			y := b.Min.Y + row*bh*2
+			upper := sample(m, image.Rect(x, y, x+bw, y+bh))
+			lower := sample(m, image.Rect(x, y+bh, x+bw, y+bh*2))
+
+			var fg, bg, s string
+			switch {
+			case upper.transparent && lower.transparent:
+				s = " "
+			case upper.transparent:
+				fg, s = mode.sgr(true, lower.c), lowerHalfBlock
+			case lower.transparent:
+				fg, s = mode.sgr(true, upper.c), upperHalfBlock
+			default:
+				fg, bg, s = mode.sgr(true, upper.c), mode.sgr(false, lower.c), upperHalfBlock
+			}
+
+			// reset if we need default background or default colors
+			if (bg == "" && prevBG != "") || (fg == "" && prevFG != "") {
+				bwr.WriteString("\x1b[0m")
+				prevFG, prevBG = "", ""
+			}
+			if fg != prevFG {
+				bwr.WriteString(fg)
+			}
+			if bg != prevBG {
+				bwr.WriteString(bg)
+			}
+			prevFG, prevBG = fg, bg
+			bwr.WriteString(s)
+		}
+		bwr.WriteString("\x1b[0m")
+		if row < rows-1 {
+			bwr.WriteString("\n")
+		}
+	}
+
+	return bwr.Flush()
+}
+
+type Display struct {
+	Mode Mode
+}
+
+func (d Display) Name() string {
+	switch d.Mode {
+	case Color256:
+		return "ansi256"
+	case Color16:
+		return "ansi16"
+	default:
+		return "ansi"
+	}
+}
+
+func (d Display) Detect(f *os.File) bool {
+	if d.Mode == TrueColor && !IsTrueColor() {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
+
+func (Display) PixelResolution(f *os.File) (display.Resolution, error) { return PixelResolution(f) }
+func (d Display) Image(w io.Writer, m image.Image, r display.Resolution) error {
+	return Image(w, m, d.Mode, r.Width/CellWidth)
+}
+func (Display) ClearScrollback(w io.Writer) error { return display.ClearScrollback(w) }
+func (Display) IsText() bool                      { return true }
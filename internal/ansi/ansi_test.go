@@ -0,0 +1,86 @@
+package ansi_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/wader/ffcat/internal/ansi"
+)
+
+// halves returns a one cell image with upper and lower half colors
+func halves(upper color.Color, lower color.Color) image.Image {
+	m := image.NewNRGBA(image.Rect(0, 0, ansi.CellWidth, ansi.CellHeight))
+	for y := 0; y < ansi.CellHeight; y++ {
+		for x := 0; x < ansi.CellWidth; x++ {
+			if y < ansi.CellHeight/2 {
+				m.Set(x, y, upper)
+			} else {
+				m.Set(x, y, lower)
+			}
+		}
+	}
+	return m
+}
+
+func TestImage(t *testing.T) {
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	transparent := color.NRGBA{}
+
+	testCases := []struct {
+		name     string
+		m        image.Image
+		mode     ansi.Mode
+		columns  int
+		expected string
+	}{
+		{name: "fg and bg", m: halves(red, blue), mode: ansi.TrueColor, expected: "\x1b[38;2;255;0;0m\x1b[48;2;0;0;255m▀\x1b[0m"},
+		{name: "transparent lower", m: halves(red, transparent), mode: ansi.TrueColor, expected: "\x1b[38;2;255;0;0m▀\x1b[0m"},
+		{name: "transparent upper", m: halves(transparent, blue), mode: ansi.TrueColor, expected: "\x1b[38;2;0;0;255m▄\x1b[0m"},
+		{name: "transparent", m: halves(transparent, transparent), mode: ansi.TrueColor, expected: " \x1b[0m"},
+		{name: "256 colors", m: halves(red, blue), mode: ansi.Color256, expected: "\x1b[38;5;196m\x1b[48;5;21m▀\x1b[0m"},
+		{name: "16 colors", m: halves(red, blue), mode: ansi.Color16, expected: "\x1b[91m\x1b[44m▀\x1b[0m"},
+		{
+			name: "rows",
+			m: func() image.Image {
+				m := image.NewNRGBA(image.Rect(0, 0, ansi.CellWidth, ansi.CellHeight*2))
+				for y := 0; y < ansi.CellHeight*2; y++ {
+					for x := 0; x < ansi.CellWidth; x++ {
+						m.Set(x, y, red)
+					}
+				}
+				return m
+			}(),
+			mode:     ansi.TrueColor,
+			expected: "\x1b[38;2;255;0;0m\x1b[48;2;255;0;0m▀\x1b[0m\n\x1b[38;2;255;0;0m\x1b[48;2;255;0;0m▀\x1b[0m",
+		},
+		{
+			name: "columns downscale",
+			m: func() image.Image {
+				m := image.NewNRGBA(image.Rect(0, 0, ansi.CellWidth*4, ansi.CellHeight*2))
+				for y := 0; y < ansi.CellHeight*2; y++ {
+					for x := 0; x < ansi.CellWidth*4; x++ {
+						m.Set(x, y, red)
+					}
+				}
+				return m
+			}(),
+			mode:     ansi.TrueColor,
+			columns:  2,
+			expected: "\x1b[38;2;255;0;0m\x1b[48;2;255;0;0m▀▀\x1b[0m",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := ansi.Image(b, tC.m, tC.mode, tC.columns); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tC.expected {
+				t.Errorf("expected %q, got %q", tC.expected, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package ansi
+
+import (
+	"image/color"
+)
+
+// standard xterm colors
+var palette16 = []color.RGBA{
+	{0x00, 0x00, 0x00, 0xff},
+	{0xcd, 0x00, 0x00, 0xff},
+	{0x00, 0xcd, 0x00, 0xff},
+	{0xcd, 0xcd, 0x00, 0xff},
+	{0x00, 0x00, 0xee, 0xff},
+	{0xcd, 0x00, 0xcd, 0xff},
+	{0x00, 0xcd, 0xcd, 0xff},
+	{0xe5, 0xe5, 0xe5, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff},
+	{0xff, 0x00, 0x00, 0xff},
+	{0x00, 0xff, 0x00, 0xff},
+	{0xff, 0xff, 0x00, 0xff},
+	{0x5c, 0x5c, 0xff, 0xff},
+	{0xff, 0x00, 0xff, 0xff},
+	{0x00, 0xff, 0xff, 0xff},
+	{0xff, 0xff, 0xff, 0xff},
+}
+
+// xterm 256 colors, 16 system colors, 6x6x6 color cube and 24 grays
+var palette256 = func() []color.RGBA {
+	p := append([]color.RGBA{}, palette16...)
+	levels := []uint8{0x00, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+	for _, r := range levels {
+		for _, g := range levels {
+			for _, b := range levels {
+				p = append(p, color.RGBA{r, g, b, 0xff})
+			}
+		}
+	}
+	for i := 0; i < 24; i++ {
+		v := uint8(8 + i*10)
+		p = append(p, color.RGBA{v, v, v, 0xff})
+	}
+	return p
+}()
+
+// distance is "redmean" weighted euclidean distance, a cheap approximation
+// of perceived color difference
+func distance(a, b color.RGBA) int {
+	rmean := (int(a.R) + int(b.R)) / 2
+	dr := int(a.R) - int(b.R)
+	dg := int(a.G) - int(b.G)
+	db := int(a.B) - int(b.B)
+	return (((512 + rmean) * dr * dr) >> 8) + 4*dg*dg + (((767 - rmean) * db * db) >> 8)
+}
+
+func nearest(p []color.RGBA, c color.RGBA) int {
+	bi, bd := 0, -1
+	for i, pc := range p {
+		if d := distance(c, pc); bd == -1 || d < bd {
+			bi, bd = i, d
+		}
+	}
+	return bi
+}
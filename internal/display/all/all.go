@@ -0,0 +1,22 @@
+package all
+
+import (
+	"github.com/wader/ffcat/internal/ansi"
+	"github.com/wader/ffcat/internal/display"
+	"github.com/wader/ffcat/internal/iterm2"
+	"github.com/wader/ffcat/internal/kitty"
+	"github.com/wader/ffcat/internal/sixel"
+	"github.com/wader/ffcat/internal/textart"
+)
+
+// Displays in order of preference when detecting
+var Displays = []display.Display{
+	kitty.Display{},
+	iterm2.Display{},
+	sixel.Display{},
+	ansi.Display{Mode: ansi.TrueColor},
+	ansi.Display{Mode: ansi.Color256},
+	ansi.Display{Mode: ansi.Color16},
+	textart.Display{Mode: textart.Braille},
+	textart.Display{Mode: textart.ASCII},
+}
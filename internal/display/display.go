@@ -0,0 +1,117 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Resolution of terminal in pixels, align is size of a cell
+type Resolution struct {
+	Width       int
+	Height      int
+	WidthAlign  int
+	HeightAlign int
+}
+
+type Display interface {
+	Name() string
+	// Detect checks if terminal connected to f supports display
+	Detect(f *os.File) bool
+	PixelResolution(f *os.File) (Resolution, error)
+	Image(w io.Writer, m image.Image, r Resolution) error
+	ClearScrollback(w io.Writer) error
+	// IsText is true if images are drawn as lines of text
+	IsText() bool
+}
+
+// Animator is implemented by displays that can show animations
+type Animator interface {
+	Animation(w io.Writer, gif []byte, r Resolution) error
+}
+
+// ClearScrollback clears screen and scrollback using standard ANSI codes
+func ClearScrollback(w io.Writer) error {
+	if _, err := w.Write([]byte("\x1b[H\x1b[2J\x1b[3J")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Size of a cell in virtual pixels for displays that draw text. Renderers
+// produce images for a pixel resolution so pretend cells have a common pixel
+// size and downscale.
+const (
+	TextCellWidth  = 8
+	TextCellHeight = 16
+)
+
+// TextPixelResolution returns resolution of terminal connected to f in text
+// cell virtual pixels
+func TextPixelResolution(f *os.File) (Resolution, error) {
+	w, h, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return Resolution{}, err
+	}
+	return Resolution{
+		Width:       w * TextCellWidth,
+		Height:      h * TextCellHeight,
+		WidthAlign:  TextCellWidth,
+		HeightAlign: TextCellHeight,
+	}, nil
+}
+
+// speed over size, image is only sent to the terminal
+var pngEncoder = png.Encoder{CompressionLevel: png.BestSpeed}
+
+// EncodePNG encodes m as PNG for displays that send PNG to the terminal
+func EncodePNG(w io.Writer, m image.Image) error {
+	return pngEncoder.Encode(w, m)
+}
+
+// Rows returns number of terminal rows the cursor moves down when m is shown
+// followed by a newline. Text leaves the cursor on the last row of the image
+// while graphics protocols leave it on the row below.
+func Rows(d Display, m image.Image, r Resolution) int {
+	if r.HeightAlign <= 0 {
+		return 0
+	}
+	rows := (m.Bounds().Dy() + r.HeightAlign - 1) / r.HeightAlign
+	if d.IsText() {
+		return rows
+	}
+	return rows + 1
+}
+
+// Find display by name
+func Find(ds []Display, name string) (Display, error) {
+	for _, d := range ds {
+		if d.Name() == name {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown output %q, should be one of: %s", name, strings.Join(Names(ds), ", "))
+}
+
+// Detect returns first display that supports terminal connected to f
+func Detect(ds []Display, f *os.File) (Display, error) {
+	for _, d := range ds {
+		if d.Detect(f) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("no output supported by terminal, force one of: %s", strings.Join(Names(ds), ", "))
+}
+
+func Names(ds []Display) []string {
+	var ns []string
+	for _, d := range ds {
+		ns = append(ns, d.Name())
+	}
+	return ns
+}
@@ -1,13 +1,39 @@
 package goffmpeg
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
 	"reflect"
 	"time"
 
 	"github.com/wader/ffcat/internal/goffmpeg/features"
+	"github.com/wader/ffcat/internal/goffmpeg/internal/execextra"
 )
 
+// CmdSetupFn is called with ffmpeg and ffprobe commands before they are
+// started if set, ex: to set SysProcAttr
+var CmdSetupFn func(c *exec.Cmd)
+
+// KillFn is called to kill ffmpeg and ffprobe processes when context is done
+// if set, default is to only kill the process
+var KillFn func(p *os.Process) error
+
+func command(ctx context.Context, path string) *execextra.Cmd {
+	var c *execextra.Cmd
+	if ctx != nil {
+		c = execextra.CommandContext(ctx, path)
+		c.Kill = KillFn
+	} else {
+		c = execextra.Command(path)
+	}
+	if CmdSetupFn != nil {
+		CmdSetupFn(c.Cmd)
+	}
+	return c
+}
+
 // Printer is something that printfs (used for debug logging)
 type Printer interface {
 	Printf(format string, v ...interface{})
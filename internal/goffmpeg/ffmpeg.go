@@ -371,11 +371,7 @@ func (fm *FFmpegCmd) progressLine(line string) {
 }
 
 func (fm *FFmpegCmd) Start() error {
-	if fm.Context != nil {
-		fm.cmd = execextra.CommandContext(fm.Context, FFmpegPath)
-	} else {
-		fm.cmd = execextra.Command(FFmpegPath)
-	}
+	fm.cmd = command(fm.Context, FFmpegPath)
 	for _, closer := range fm.CloseAfterStart {
 		fm.cmd.CloseAfterStart(closer)
 	}
@@ -21,6 +21,7 @@ var FFprobePath = "ffprobe"
 type FFProbeResult struct {
 	Format  FFProbeFormat          `json:"format"`
 	Streams []FFProbeStream        `json:"streams"`
+	Frames  []FFProbeFrame         `json:"frames"`
 	Raw     map[string]interface{} `json:"raw"`
 }
 
@@ -76,6 +77,9 @@ type FFProbeStream struct {
 	NalLengthSize      string     `json:"nal_length_size"`
 	Tags               Metadata   `json:"tags"`
 	SideDataList       []SideData `json:"side_data_list"`
+
+	ID          string         `json:"id"`
+	Disposition map[string]int `json:"disposition"`
 }
 
 func (fps FFProbeStream) Rotation() int {
@@ -87,6 +91,20 @@ func (fps FFProbeStream) Rotation() int {
 	return 0
 }
 
+// FrameRate parses avg_frame_rate like "30000/1001", zero if unknown
+func (fps FFProbeStream) FrameRate() float64 {
+	parts := strings.SplitN(fps.AvgFrameRate, "/", 2)
+	n, _ := strconv.ParseFloat(parts[0], 64)
+	d := 1.0
+	if len(parts) > 1 {
+		d, _ = strconv.ParseFloat(parts[1], 64)
+	}
+	if n == 0 || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
 func (fps FFProbeStream) DisplayWidth() uint {
 	switch fps.Rotation() {
 	case -90, 90:
@@ -103,6 +121,30 @@ func (fps FFProbeStream) DisplayHeight() uint {
 	return fps.Height
 }
 
+// FFProbeFrame ffprobe frame result, only with FFProbeCmd.ShowFrames
+type FFProbeFrame struct {
+	MediaType               string `json:"media_type"`
+	StreamIndex             uint   `json:"stream_index"`
+	KeyFrame                int    `json:"key_frame"`
+	Pts                     int64  `json:"pts"`
+	PtsTime                 string `json:"pts_time"`
+	PktDts                  int64  `json:"pkt_dts"`
+	PktDtsTime              string `json:"pkt_dts_time"`
+	BestEffortTimestampTime string `json:"best_effort_timestamp_time"`
+	PktSize                 string `json:"pkt_size"`
+	PictType                string `json:"pict_type"`
+}
+
+// Time is best effort timestamp in seconds, same as ffmpeg uses
+func (fpf FFProbeFrame) Time() float64 {
+	t := fpf.BestEffortTimestampTime
+	if t == "" {
+		t = fpf.PtsTime
+	}
+	v, _ := strconv.ParseFloat(t, 64)
+	return v
+}
+
 // FFProbeFormat ffprobe format result
 type FFProbeFormat struct {
 	Filename       string   `json:"filename"`
@@ -176,6 +218,11 @@ func (fpr FFProbeResult) String() string {
 type FFProbeCmd struct {
 	Flags []string
 	Input Input
+	// ShowFrames adds frames to result, can be slow so limit using
+	// SelectStreams specifier and ReadIntervals, ex: 10%+5
+	ShowFrames    bool
+	SelectStreams string
+	ReadIntervals string
 
 	ProbeResult FFProbeResult `json:"-"`
 
@@ -191,17 +238,22 @@ type FFProbeCmd struct {
 
 // Start ffprobe cmd
 func (fp *FFProbeCmd) Start() error {
-	if fp.Context != nil {
-		fp.cmd = execextra.CommandContext(fp.Context, FFprobePath)
-	} else {
-		fp.cmd = execextra.Command(FFprobePath)
-	}
+	fp.cmd = command(fp.Context, FFprobePath)
 	fp.cmd.Args = append(fp.cmd.Args,
 		"-hide_banner",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
 	)
+	if fp.ShowFrames {
+		fp.cmd.Args = append(fp.cmd.Args, "-show_frames")
+	}
+	if fp.SelectStreams != "" {
+		fp.cmd.Args = append(fp.cmd.Args, "-select_streams", fp.SelectStreams)
+	}
+	if fp.ReadIntervals != "" {
+		fp.cmd.Args = append(fp.cmd.Args, "-read_intervals", fp.ReadIntervals)
+	}
 	fp.cmd.Args = append(fp.cmd.Args, fp.Flags...)
 	fp.cmd.Args = append(fp.cmd.Args, kvargs.MapToSortedArgs(fp.Input.Options, kvargs.OptionArg(""))...)
 	fp.cmd.Args = append(fp.cmd.Args, fp.Input.Flags...)
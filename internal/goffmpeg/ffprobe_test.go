@@ -3,6 +3,7 @@ package goffmpeg_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"log"
 	"testing"
 	"time"
@@ -21,3 +22,37 @@ func TestProbe(t *testing.T) {
 
 	log.Printf("pi: %#+v\n", p.ProbeResult)
 }
+
+func TestProbeFrames(t *testing.T) {
+	var pr goffmpeg.FFProbeResult
+	if err := json.Unmarshal([]byte(`{"frames": [
+		{"media_type": "video", "stream_index": 0, "key_frame": 1, "pts": 512, "pts_time": "0.040000", "best_effort_timestamp_time": "0.040000", "pkt_size": "4312", "pict_type": "I"},
+		{"media_type": "video", "stream_index": 0, "key_frame": 0, "pts_time": "0.080000", "pkt_size": "312", "pict_type": "B"}
+	]}`), &pr); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		expectedPictType string
+		expectedKeyFrame int
+		expectedTime     float64
+	}{
+		{expectedPictType: "I", expectedKeyFrame: 1, expectedTime: 0.04},
+		{expectedPictType: "B", expectedKeyFrame: 0, expectedTime: 0.08},
+	}
+	if len(pr.Frames) != len(testCases) {
+		t.Fatalf("expected %d frames, got %d", len(testCases), len(pr.Frames))
+	}
+	for i, tC := range testCases {
+		f := pr.Frames[i]
+		if tC.expectedPictType != f.PictType {
+			t.Errorf("expected %q, got %q", tC.expectedPictType, f.PictType)
+		}
+		if tC.expectedKeyFrame != f.KeyFrame {
+			t.Errorf("expected %d, got %d", tC.expectedKeyFrame, f.KeyFrame)
+		}
+		if tC.expectedTime != f.Time() {
+			t.Errorf("expected %v, got %v", tC.expectedTime, f.Time())
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package goffmpeg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FrameSelect is a parsed frame selection, comma separated union of:
+// 12 frame number, 10-20 frame range, /5 every 5th frame, key for keyframes,
+// i, p or b picture type and scene or scene:0.4 for scene changes.
+// Frame numbers are counted from first decoded frame.
+type FrameSelect struct {
+	spec  string
+	exprs []string
+	// same as exprs, nil if depends on frame content
+	matchers []func(n int, f FFProbeFrame) bool
+	count    int
+}
+
+var pictTypes = map[string]string{
+	"i": "I",
+	"p": "P",
+	"b": "B",
+}
+
+// DefaultSceneThreshold is used for scene without threshold
+const DefaultSceneThreshold = 0.3
+
+// ParseFrameSelect parses frame selection
+func ParseFrameSelect(spec string) (FrameSelect, error) {
+	fs := FrameSelect{spec: spec}
+	countKnown := true
+	// frame number ranges, counted after merging overlaps
+	var ranges [][2]int
+
+	for _, p := range strings.Split(spec, ",") {
+		switch {
+		case isDigits(p):
+			n, _ := strconv.Atoi(p)
+			fs.exprs = append(fs.exprs, fmt.Sprintf("eq(n,%d)", n))
+			fs.matchers = append(fs.matchers, func(fn int, f FFProbeFrame) bool { return fn == n })
+			ranges = append(ranges, [2]int{n, n})
+		case strings.Contains(p, "-"):
+			parts := strings.SplitN(p, "-", 2)
+			if !isDigits(parts[0]) || !isDigits(parts[1]) {
+				return FrameSelect{}, fmt.Errorf("%s: invalid frame range %q", spec, p)
+			}
+			from, _ := strconv.Atoi(parts[0])
+			to, _ := strconv.Atoi(parts[1])
+			if to < from {
+				return FrameSelect{}, fmt.Errorf("%s: frame range %q ends before start", spec, p)
+			}
+			fs.exprs = append(fs.exprs, fmt.Sprintf("between(n,%d,%d)", from, to))
+			fs.matchers = append(fs.matchers, func(fn int, f FFProbeFrame) bool { return fn >= from && fn <= to })
+			ranges = append(ranges, [2]int{from, to})
+		case strings.HasPrefix(p, "/"):
+			n, err := strconv.Atoi(p[1:])
+			if err != nil || n < 1 {
+				return FrameSelect{}, fmt.Errorf("%s: invalid frame interval %q", spec, p)
+			}
+			fs.exprs = append(fs.exprs, fmt.Sprintf("not(mod(n,%d))", n))
+			fs.matchers = append(fs.matchers, func(fn int, f FFProbeFrame) bool { return fn%n == 0 })
+			countKnown = false
+		case p == "key":
+			fs.exprs = append(fs.exprs, "key")
+			fs.matchers = append(fs.matchers, func(fn int, f FFProbeFrame) bool { return f.KeyFrame == 1 })
+			countKnown = false
+		case pictTypes[p] != "":
+			pictType := pictTypes[p]
+			fs.exprs = append(fs.exprs, fmt.Sprintf("eq(pict_type,%s)", pictType))
+			fs.matchers = append(fs.matchers, func(fn int, f FFProbeFrame) bool { return f.PictType == pictType })
+			countKnown = false
+		case p == "scene" || strings.HasPrefix(p, "scene:"):
+			threshold := DefaultSceneThreshold
+			if p != "scene" {
+				var err error
+				threshold, err = strconv.ParseFloat(strings.TrimPrefix(p, "scene:"), 64)
+				if err != nil || threshold <= 0 || threshold >= 1 {
+					return FrameSelect{}, fmt.Errorf("%s: scene threshold has to be between 0 and 1", spec)
+				}
+			}
+			fs.exprs = append(fs.exprs, fmt.Sprintf("gt(scene,%s)", strconv.FormatFloat(threshold, 'f', -1, 64)))
+			fs.matchers = append(fs.matchers, nil)
+			countKnown = false
+		default:
+			return FrameSelect{}, fmt.Errorf("%s: invalid frame selection %q", spec, p)
+		}
+	}
+
+	if countKnown {
+		fs.count = uniqueCount(ranges)
+	}
+
+	return fs, nil
+}
+
+// uniqueCount returns number of unique numbers in inclusive ranges
+func uniqueCount(ranges [][2]int) int {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	count := 0
+	next := 0
+	for _, r := range ranges {
+		from, to := r[0], r[1]
+		if from < next {
+			from = next
+		}
+		if to >= from {
+			count += to - from + 1
+			next = to + 1
+		}
+	}
+	return count
+}
+
+func (fs FrameSelect) String() string { return fs.spec }
+
+// Expr returns select filter expression, non-zero for selected frames
+func (fs FrameSelect) Expr() string { return strings.Join(fs.exprs, "+") }
+
+// Count returns number of selected frames, 0 if not known
+func (fs FrameSelect) Count() int { return fs.count }
+
+// Match reports if frame f with frame number n is selected, ok is false if
+// selection depends on frame content, ex: scene
+func (fs FrameSelect) Match(n int, f FFProbeFrame) (selected bool, ok bool) {
+	for _, m := range fs.matchers {
+		if m == nil {
+			return false, false
+		}
+		if m(n, f) {
+			selected = true
+		}
+	}
+	return selected, true
+}
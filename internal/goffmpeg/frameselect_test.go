@@ -0,0 +1,102 @@
+package goffmpeg_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+)
+
+func TestFrameSelect(t *testing.T) {
+	testCases := []struct {
+		spec          string
+		expectedExpr  string
+		expectedCount int
+		expectedErr   string
+	}{
+		{spec: "12", expectedExpr: "eq(n,12)", expectedCount: 1},
+		{spec: "0,5,10-19", expectedExpr: "eq(n,0)+eq(n,5)+between(n,10,19)", expectedCount: 12},
+		{spec: "1-10,5", expectedExpr: "between(n,1,10)+eq(n,5)", expectedCount: 10},
+		{spec: "5-10,0-7,7,12", expectedExpr: "between(n,5,10)+between(n,0,7)+eq(n,7)+eq(n,12)", expectedCount: 12},
+		{spec: "/5", expectedExpr: "not(mod(n,5))"},
+		{spec: "key", expectedExpr: "key"},
+		{spec: "i,b", expectedExpr: "eq(pict_type,I)+eq(pict_type,B)"},
+		{spec: "scene", expectedExpr: "gt(scene,0.3)"},
+		{spec: "scene:0.45,0", expectedExpr: "gt(scene,0.45)+eq(n,0)"},
+		{spec: "", expectedErr: `: invalid frame selection ""`},
+		{spec: "x", expectedErr: `x: invalid frame selection "x"`},
+		{spec: "10-a", expectedErr: `10-a: invalid frame range "10-a"`},
+		{spec: "20-10", expectedErr: `20-10: frame range "20-10" ends before start`},
+		{spec: "/0", expectedErr: `/0: invalid frame interval "/0"`},
+		{spec: "scene:2", expectedErr: "scene:2: scene threshold has to be between 0 and 1"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.spec, func(t *testing.T) {
+			fs, err := goffmpeg.ParseFrameSelect(tC.spec)
+			if tC.expectedErr != "" {
+				if err == nil || err.Error() != tC.expectedErr {
+					t.Errorf("expected error %q, got %v", tC.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tC.expectedExpr != fs.Expr() {
+				t.Errorf("expected %q, got %q", tC.expectedExpr, fs.Expr())
+			}
+			if tC.expectedCount != fs.Count() {
+				t.Errorf("expected %d, got %d", tC.expectedCount, fs.Count())
+			}
+		})
+	}
+}
+
+func TestFrameSelectMatch(t *testing.T) {
+	frames := []goffmpeg.FFProbeFrame{
+		{KeyFrame: 1, PictType: "I"},
+		{PictType: "B"},
+		{PictType: "P"},
+		{PictType: "B"},
+		{KeyFrame: 1, PictType: "I"},
+		{PictType: "P"},
+	}
+
+	testCases := []struct {
+		spec       string
+		expected   []int
+		expectedOk bool
+	}{
+		{spec: "1,3-4", expected: []int{1, 3, 4}, expectedOk: true},
+		{spec: "/2", expected: []int{0, 2, 4}, expectedOk: true},
+		{spec: "key", expected: []int{0, 4}, expectedOk: true},
+		{spec: "p,b", expected: []int{1, 2, 3, 5}, expectedOk: true},
+		{spec: "key,scene", expected: nil, expectedOk: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.spec, func(t *testing.T) {
+			fs, err := goffmpeg.ParseFrameSelect(tC.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual []int
+			actualOk := true
+			for n, f := range frames {
+				selected, ok := fs.Match(n, f)
+				if !ok {
+					actualOk = false
+					break
+				}
+				if selected {
+					actual = append(actual, n)
+				}
+			}
+			if tC.expectedOk != actualOk {
+				t.Errorf("expected %v, got %v", tC.expectedOk, actualOk)
+			}
+			if fmt.Sprint(tC.expected) != fmt.Sprint(actual) {
+				t.Errorf("expected %v, got %v", tC.expected, actual)
+			}
+		})
+	}
+}
@@ -4,10 +4,12 @@ package execextra
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"sync"
+	"syscall"
 )
 
 type closeOnce struct {
@@ -33,13 +35,20 @@ func Command(name string, arg ...string) *Cmd {
 
 // CommandContext see go stdlib exec.CommandContext
 func CommandContext(ctx context.Context, name string, arg ...string) *Cmd {
-	return &Cmd{Cmd: exec.CommandContext(ctx, name, arg...)}
+	return &Cmd{Cmd: exec.CommandContext(ctx, name, arg...), ctx: ctx}
 }
 
 // Cmd is a go stdlib exec.Cmd with some ExtraFiles additions
 type Cmd struct {
 	*exec.Cmd
 
+	// Kill is called with process when context is done if set, ex: to also
+	// kill child processes
+	Kill func(p *os.Process) error
+
+	ctx       context.Context
+	stopWatch chan struct{}
+
 	// design borrowed from go src/exec/exec.go
 	closeAfterStart []io.Closer
 	closeAfterWait  []io.Closer
@@ -61,6 +70,16 @@ func (c *Cmd) Start() error {
 		return err
 	}
 	c.closeDescriptors(c.closeAfterStart)
+	if c.ctx != nil && c.Kill != nil {
+		c.stopWatch = make(chan struct{})
+		go func(p *os.Process) {
+			select {
+			case <-c.ctx.Done():
+				_ = c.Kill(p)
+			case <-c.stopWatch:
+			}
+		}(c.Process)
+	}
 
 	c.copyErrCh = make(chan error, len(c.copyFns))
 	for _, fn := range c.copyFns {
@@ -83,6 +102,9 @@ func (c *Cmd) Run() error {
 // Wait see go stdlib cmd.Wait
 func (c *Cmd) Wait() error {
 	err := c.Cmd.Wait()
+	if c.stopWatch != nil {
+		close(c.stopWatch)
+	}
 
 	var copyErr error
 	for range c.copyFns {
@@ -132,6 +154,10 @@ func (c *Cmd) ExtraIn(r io.Reader) (childFD uintptr, err error) {
 	c.copyFns = append(c.copyFns, func() error {
 		_, err := io.Copy(wc, r)
 		wc.Close()
+		// child is done reading, same as stdlib does for stdin
+		if errors.Is(err, syscall.EPIPE) {
+			return nil
+		}
 		return err
 	})
 
@@ -0,0 +1,40 @@
+package goffmpeg
+
+import (
+	"image"
+	"io"
+)
+
+// RawVideoOutput returns output that writes frames from specifier to w as
+// rawvideo rgba, read frames using RawVideoReader. Frame size has to be known,
+// ex: scale to a fixed size.
+func RawVideoOutput(specifier string, w io.Writer) *Output {
+	return &Output{
+		Maps: []*Map{
+			{
+				Specifier: specifier,
+				Codec:     "rawvideo",
+			},
+		},
+		Format: "rawvideo",
+		Flags:  []string{"-pix_fmt", "rgba"},
+		File:   w,
+	}
+}
+
+// RawVideoReader reads rawvideo rgba frames of known size
+type RawVideoReader struct {
+	R      io.Reader
+	Width  int
+	Height int
+}
+
+// ReadFrame reads next frame, io.EOF if there are no more frames and
+// io.ErrUnexpectedEOF if last frame is incomplete
+func (rr RawVideoReader) ReadFrame() (*image.NRGBA, error) {
+	m := image.NewNRGBA(image.Rect(0, 0, rr.Width, rr.Height))
+	if _, err := io.ReadFull(rr.R, m.Pix); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,38 @@
+package goffmpeg_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+)
+
+func TestRawVideoReader(t *testing.T) {
+	// two 2x1 frames and a incomplete one
+	bs := []byte{
+		1, 2, 3, 4, 5, 6, 7, 8,
+		9, 10, 11, 12, 13, 14, 15, 16,
+		17,
+	}
+	rr := goffmpeg.RawVideoReader{R: bytes.NewReader(bs), Width: 2, Height: 1}
+
+	for i := 0; i < 2; i++ {
+		m, err := rr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m.Pix, bs[i*8:i*8+8]) {
+			t.Errorf("expected %v, got %v", bs[i*8:i*8+8], m.Pix)
+		}
+		if m.Bounds().Dx() != 2 || m.Bounds().Dy() != 1 {
+			t.Errorf("expected 2x1, got %v", m.Bounds())
+		}
+	}
+	if _, err := rr.ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	if _, err := rr.ReadFrame(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
@@ -0,0 +1,142 @@
+package goffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StreamSpecifier is a parsed ffmpeg stream specifier, ex: v:0, a, s:1, 0:3,
+// m:language:eng. Input file index is allowed but has to be 0. Programs are
+// not supported.
+type StreamSpecifier struct {
+	spec    string
+	filters []func(s FFProbeStream) bool
+	// index among streams matching filters, -1 for all
+	index int
+}
+
+var streamTypes = map[string]func(s FFProbeStream) bool{
+	"v": func(s FFProbeStream) bool { return s.CodecType == "video" },
+	// not attached pictures like cover art
+	"V": func(s FFProbeStream) bool { return s.CodecType == "video" && s.Disposition["attached_pic"] == 0 },
+	"a": func(s FFProbeStream) bool { return s.CodecType == "audio" },
+	"s": func(s FFProbeStream) bool { return s.CodecType == "subtitle" },
+	"d": func(s FFProbeStream) bool { return s.CodecType == "data" },
+	"t": func(s FFProbeStream) bool { return s.CodecType == "attachment" },
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseStreamSpecifier parses stream specifier
+func ParseStreamSpecifier(spec string) (StreamSpecifier, error) {
+	ss := StreamSpecifier{spec: spec, index: -1}
+	parts := strings.Split(spec, ":")
+
+	if len(parts) > 1 && isDigits(parts[0]) {
+		if parts[0] != "0" {
+			return StreamSpecifier{}, fmt.Errorf("%s: only input file index 0 is allowed", spec)
+		}
+		parts = parts[1:]
+	}
+
+	for len(parts) > 0 {
+		p := parts[0]
+		parts = parts[1:]
+
+		switch {
+		case isDigits(p):
+			if len(parts) > 0 {
+				return StreamSpecifier{}, fmt.Errorf("%s: index has to be last", spec)
+			}
+			ss.index, _ = strconv.Atoi(p)
+		case streamTypes[p] != nil:
+			if len(ss.filters) > 0 {
+				return StreamSpecifier{}, fmt.Errorf("%s: stream type has to be first", spec)
+			}
+			ss.filters = append(ss.filters, streamTypes[p])
+		case p == "m":
+			if len(parts) == 0 || parts[0] == "" {
+				return StreamSpecifier{}, fmt.Errorf("%s: metadata key missing", spec)
+			}
+			key := parts[0]
+			// value can include ":"
+			value, hasValue := "", len(parts) > 1
+			if hasValue {
+				value = strings.Join(parts[1:], ":")
+			}
+			parts = nil
+			ss.filters = append(ss.filters, func(s FFProbeStream) bool {
+				v, ok := s.Tags.ToMap()[key]
+				return ok && (!hasValue || v == value)
+			})
+		case p == "i" || strings.HasPrefix(p, "#"):
+			id := strings.TrimPrefix(p, "#")
+			if p == "i" {
+				if len(parts) == 0 {
+					return StreamSpecifier{}, fmt.Errorf("%s: stream id missing", spec)
+				}
+				id, parts = parts[0], parts[1:]
+			}
+			n, err := strconv.ParseInt(id, 0, 64)
+			if err != nil {
+				return StreamSpecifier{}, fmt.Errorf("%s: invalid stream id %q", spec, id)
+			}
+			ss.filters = append(ss.filters, func(s FFProbeStream) bool {
+				sn, err := strconv.ParseInt(s.ID, 0, 64)
+				return err == nil && sn == n
+			})
+		case p == "u":
+			ss.filters = append(ss.filters, func(s FFProbeStream) bool {
+				switch s.CodecType {
+				case "video":
+					return s.Width > 0 && s.Height > 0
+				case "audio":
+					return s.Channels > 0 && s.SampleRate != ""
+				}
+				return true
+			})
+		case p == "p":
+			return StreamSpecifier{}, fmt.Errorf("%s: programs are not supported", spec)
+		default:
+			return StreamSpecifier{}, fmt.Errorf("%s: invalid stream specifier %q", spec, p)
+		}
+	}
+
+	return ss, nil
+}
+
+func (ss StreamSpecifier) String() string { return ss.spec }
+
+// Select returns streams matching specifier
+func (ss StreamSpecifier) Select(streams []FFProbeStream) []FFProbeStream {
+	var r []FFProbeStream
+	n := 0
+	for _, s := range streams {
+		match := true
+		for _, f := range ss.filters {
+			if !f(s) {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		if ss.index == -1 || ss.index == n {
+			r = append(r, s)
+		}
+		n++
+	}
+	return r
+}
@@ -0,0 +1,72 @@
+package goffmpeg_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+)
+
+func TestStreamSpecifier(t *testing.T) {
+	streams := []goffmpeg.FFProbeStream{
+		{Index: 0, CodecType: "video", ID: "0x100", Width: 320, Height: 240},
+		{Index: 1, CodecType: "audio", ID: "0x101", Channels: 2, SampleRate: "48000", Tags: goffmpeg.Metadata{Language: "eng"}},
+		{Index: 2, CodecType: "audio", ID: "0x102", Tags: goffmpeg.Metadata{Language: "swe"}},
+		{Index: 3, CodecType: "subtitle", Tags: goffmpeg.Metadata{Language: "eng"}},
+		{Index: 4, CodecType: "subtitle", Tags: goffmpeg.Metadata{Language: "swe"}},
+		{Index: 5, CodecType: "video", Disposition: map[string]int{"attached_pic": 1}},
+	}
+
+	testCases := []struct {
+		spec        string
+		expected    []uint
+		expectedErr string
+	}{
+		{spec: "v", expected: []uint{0, 5}},
+		{spec: "V", expected: []uint{0}},
+		{spec: "v:0", expected: []uint{0}},
+		{spec: "v:1", expected: []uint{5}},
+		{spec: "a", expected: []uint{1, 2}},
+		{spec: "s:1", expected: []uint{4}},
+		{spec: "3", expected: []uint{3}},
+		{spec: "0:3", expected: []uint{3}},
+		{spec: "0:a:1", expected: []uint{2}},
+		{spec: "m:language:eng", expected: []uint{1, 3}},
+		{spec: "m:language", expected: []uint{1, 2, 3, 4}},
+		{spec: "s:m:language:swe", expected: []uint{4}},
+		{spec: "#0x101", expected: []uint{1}},
+		{spec: "i:258", expected: []uint{2}},
+		{spec: "a:u", expected: []uint{1}},
+		{spec: "d", expected: nil},
+		{spec: "9", expected: nil},
+		{spec: "1:3", expectedErr: "1:3: only input file index 0 is allowed"},
+		{spec: "0:v:a", expectedErr: "0:v:a: stream type has to be first"},
+		{spec: "1:v:0", expectedErr: "1:v:0: only input file index 0 is allowed"},
+		{spec: "v:0:a", expectedErr: "v:0:a: index has to be last"},
+		{spec: "p:1", expectedErr: "p:1: programs are not supported"},
+		{spec: "x", expectedErr: `x: invalid stream specifier "x"`},
+		{spec: "m", expectedErr: "m: metadata key missing"},
+		{spec: "#abc", expectedErr: `#abc: invalid stream id "abc"`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.spec, func(t *testing.T) {
+			ss, err := goffmpeg.ParseStreamSpecifier(tC.spec)
+			if tC.expectedErr != "" {
+				if err == nil || err.Error() != tC.expectedErr {
+					t.Errorf("expected error %q, got %v", tC.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual []uint
+			for _, s := range ss.Select(streams) {
+				actual = append(actual, s.Index)
+			}
+			if fmt.Sprint(tC.expected) != fmt.Sprint(actual) {
+				t.Errorf("expected %v, got %v", tC.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Package imagefile composes and encodes images to image files.
+package imagefile
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	PNG  = "png"
+	JPEG = "jpeg"
+)
+
+// FormatFromPath returns format based on file extension
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return PNG, nil
+	case ".jpg", ".jpeg":
+		return JPEG, nil
+	}
+	return "", fmt.Errorf("%s: unknown image format, should be .png, .jpg or .jpeg", path)
+}
+
+// Stack images vertically aligned to the left
+func Stack(ms []image.Image) image.Image {
+	width, height := 0, 0
+	for _, m := range ms {
+		b := m.Bounds()
+		if b.Dx() > width {
+			width = b.Dx()
+		}
+		height += b.Dy()
+	}
+
+	sm := image.NewNRGBA(image.Rect(0, 0, width, height))
+	y := 0
+	for _, m := range ms {
+		b := m.Bounds()
+		r := image.Rect(0, y, b.Dx(), y+b.Dy())
+		draw.Draw(sm, r, m, b.Min, draw.Src)
+		y += b.Dy()
+	}
+
+	return sm
+}
+
+func Encode(w io.Writer, m image.Image, format string) error {
+	switch format {
+	case PNG:
+		return png.Encode(w, m)
+	case JPEG:
+		// no alpha in jpeg, draw on black background
+		b := m.Bounds()
+		om := image.NewRGBA(b)
+		draw.Draw(om, b, image.NewUniform(color.Black), image.Point{}, draw.Src)
+		draw.Draw(om, b, m, b.Min, draw.Over)
+		return jpeg.Encode(w, om, &jpeg.Options{Quality: 90})
+	}
+	return fmt.Errorf("unknown image format %q", format)
+}
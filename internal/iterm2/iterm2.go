@@ -2,13 +2,16 @@ package iterm2
 
 import (
 	"encoding/base64"
+	"fmt"
 	"image"
-	"image/png"
 	"io"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/wader/ffcat/internal/display"
+	"github.com/wader/ffcat/internal/passthrough"
+	"github.com/wader/ffcat/internal/termquery"
 	"golang.org/x/term"
 )
 
@@ -16,14 +19,38 @@ import (
 
 // TODO: query somehow
 func IsCompatible() bool {
-	return os.Getenv("TERM_PROGRAM") == "iTerm.app"
+	// LC_TERMINAL is passed thru ssh and is not changed by tmux
+	return os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("LC_TERMINAL") == "iTerm2"
 }
 
 func Image(w io.Writer, m image.Image) error {
 	if _, err := w.Write([]byte("\x1b]1337;File=inline=1:")); err != nil {
 		return err
 	}
-	if err := png.Encode(base64.NewEncoder(base64.StdEncoding, w), m); err != nil {
+	// close to flush last partial base64 block
+	bw := base64.NewEncoder(base64.StdEncoding, w)
+	if err := display.EncodePNG(bw, m); err != nil {
+		return err
+	}
+	if err := bw.Close(); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("\x07")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// File displays inline file, used for formats like animated GIF
+func File(w io.Writer, bs []byte) error {
+	if _, err := fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d:", len(bs)); err != nil {
+		return err
+	}
+	bw := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := bw.Write(bs); err != nil {
+		return err
+	}
+	if err := bw.Close(); err != nil {
 		return err
 	}
 	if _, err := w.Write([]byte("\x07")); err != nil {
@@ -38,33 +65,19 @@ type CellSize struct {
 	Scale  float64
 }
 
-func ReportCellSize(f *os.File) (sz CellSize, err error) {
-	os, err := term.MakeRaw(int(f.Fd()))
+func ReportCellSize(f *os.File) (CellSize, error) {
+	const prefix = "1337;ReportCellSize="
+	r, err := termquery.New(f).Query("\x1b]1337;ReportCellSize\x07", func(r termquery.Reply) bool {
+		return r.Kind == termquery.OSC && strings.HasPrefix(r.Data, prefix)
+	})
 	if err != nil {
 		return CellSize{}, err
 	}
-	defer func() { err = term.Restore(int(f.Fd()), os) }()
-
-	if _, err := f.Write([]byte("\x1b]1337;ReportCellSize\x07")); err != nil {
-		return CellSize{}, err
-	}
 
 	// note order is height:width[:scale]
 	// "\x1b]1337;ReportCellSize=14.0;6.0;1.0\x1b\\"
-	b := make([]byte, 50)
-	if _, err := f.Read(b); err != nil {
-		return CellSize{}, err
-	}
-
-	// TODO: cleanup
-	s := string(b)
-	p := "ReportCellSize="
-	start := strings.Index(s, p) + len(p)
-	stop := strings.Index(s, "\x1b\\")
-
-	whs := s[start:stop]
-	parts := strings.Split(whs, ";")
-	sz = CellSize{}
+	parts := strings.Split(strings.TrimPrefix(r.Data, prefix), ";")
+	sz := CellSize{}
 	if len(parts) > 0 {
 		sz.Height, _ = strconv.ParseFloat(parts[0], 64)
 	}
@@ -79,24 +92,22 @@ func ReportCellSize(f *os.File) (sz CellSize, err error) {
 	return sz, nil
 }
 
-type Resolution struct {
-	Width       int
-	Height      int
-	WidthAlign  int
-	HeightAlign int
-}
-
-func PixelResolution(f *os.File) (Resolution, error) {
+func PixelResolution(f *os.File) (display.Resolution, error) {
 	w, h, err := term.GetSize(int(f.Fd()))
 	if err != nil {
-		return Resolution{}, err
+		return display.Resolution{}, err
 	}
 	sz, err := ReportCellSize(f)
 	if err != nil {
-		return Resolution{}, err
+		// fallback to generic cell size query
+		cw, ch, cerr := termquery.New(f).CellSize()
+		if cerr != nil {
+			return display.Resolution{}, err
+		}
+		sz = CellSize{Width: float64(cw), Height: float64(ch), Scale: 1}
 	}
 
-	return Resolution{
+	return display.Resolution{
 		Width:       w * int(sz.Width*sz.Scale),
 		Height:      h * int(sz.Height*sz.Scale),
 		WidthAlign:  int(sz.Width * sz.Scale),
@@ -105,8 +116,30 @@ func PixelResolution(f *os.File) (Resolution, error) {
 }
 
 func ClearScrollback(w io.Writer) error {
-	if _, err := w.Write([]byte("\x1b]1337;ClearScrollback\x07")); err != nil {
+	if _, err := w.Write(passthrough.Detect().Wrap([]byte("\x1b]1337;ClearScrollback\x07"))); err != nil {
 		return err
 	}
 	return nil
 }
+
+type Display struct{}
+
+func (Display) Name() string                                           { return "iterm2" }
+func (Display) Detect(f *os.File) bool                                 { return IsCompatible() }
+func (Display) PixelResolution(f *os.File) (display.Resolution, error) { return PixelResolution(f) }
+func (Display) Image(w io.Writer, m image.Image, r display.Resolution) error {
+	pw := passthrough.NewWriter(w, passthrough.Detect())
+	if err := Image(pw, m); err != nil {
+		return err
+	}
+	return pw.Flush()
+}
+func (Display) ClearScrollback(w io.Writer) error { return ClearScrollback(w) }
+func (Display) IsText() bool                      { return false }
+func (Display) Animation(w io.Writer, bs []byte, r display.Resolution) error {
+	pw := passthrough.NewWriter(w, passthrough.Detect())
+	if err := File(pw, bs); err != nil {
+		return err
+	}
+	return pw.Flush()
+}
@@ -0,0 +1,236 @@
+package kitty
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"time"
+
+	"github.com/wader/ffcat/internal/display"
+	"github.com/wader/ffcat/internal/passthrough"
+	"github.com/wader/ffcat/internal/termquery"
+)
+
+// max payload size per escape sequence according to protocol spec
+const chunkSize = 4096
+
+func IsCompatible() bool {
+	return os.Getenv("KITTY_WINDOW_ID") != "" ||
+		os.Getenv("TERM") == "xterm-kitty" ||
+		os.Getenv("TERM") == "xterm-ghostty" ||
+		os.Getenv("TERM_PROGRAM") == "ghostty"
+}
+
+func Image(w io.Writer, m image.Image) error {
+	return ImageCells(w, m, 0, 0, 0)
+}
+
+// ImageCells displays image scaled to columns x rows cells, zero means
+// use image size. Non-zero id can be used to delete the image.
+func ImageCells(w io.Writer, m image.Image, id uint32, columns int, rows int) error {
+	// a=T transmit and display, f=100 png, q=2 suppress responses
+	control := "a=T,f=100,q=2"
+	if id != 0 {
+		control += fmt.Sprintf(",i=%d", id)
+	}
+	if columns > 0 {
+		control += fmt.Sprintf(",c=%d", columns)
+	}
+	if rows > 0 {
+		control += fmt.Sprintf(",r=%d", rows)
+	}
+	return transmit(w, control, m)
+}
+
+// transmit image as png in chunks
+func transmit(w io.Writer, control string, m image.Image) error {
+	pb := &bytes.Buffer{}
+	if err := display.EncodePNG(pb, m); err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(pb.Bytes())
+
+	for first := true; first || len(payload) > 0; first = false {
+		chunk := payload
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		payload = payload[len(chunk):]
+
+		more := 0
+		if len(payload) > 0 {
+			more = 1
+		}
+		// only first chunk has the full control data
+		c := fmt.Sprintf("m=%d", more)
+		if first {
+			c = control + "," + c
+		}
+		if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", c, chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewID returns image id based on time so that previous images are not replaced
+func NewID() uint32 {
+	return uint32(time.Now().UnixNano())%0xffffff + 1
+}
+
+// Delete deletes placements of image id and frees its data
+func Delete(w io.Writer, id uint32) error {
+	_, err := fmt.Fprintf(w, "\x1b_Ga=d,d=I,i=%d,q=2\x1b\\", id)
+	return err
+}
+
+// Animation displays GIF using animation frames
+func Animation(w io.Writer, g *gif.GIF) error {
+	if len(g.Image) == 0 {
+		return fmt.Errorf("gif has no frames")
+	}
+
+	id := NewID()
+	// gif delay is in 1/100s
+	gap := func(i int) int {
+		if i < len(g.Delay) && g.Delay[i] > 0 {
+			return g.Delay[i] * 10
+		}
+		return 100
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	if canvas.Rect.Empty() {
+		canvas = image.NewNRGBA(g.Image[0].Bounds())
+	}
+	var previous *image.NRGBA
+	for i, fm := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(canvas.Rect)
+			copy(previous.Pix, canvas.Pix)
+		}
+		draw.Draw(canvas, fm.Bounds(), fm, fm.Bounds().Min, draw.Over)
+
+		var control string
+		if i == 0 {
+			control = fmt.Sprintf("a=T,f=100,q=2,i=%d", id)
+		} else {
+			// a=f add frame, z gap in ms
+			control = fmt.Sprintf("a=f,f=100,q=2,i=%d,z=%d", id, gap(i))
+		}
+		if err := transmit(w, control, canvas); err != nil {
+			return err
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, fm.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	// set gap for first frame and start looping, s=3 run, v=1 loop forever
+	_, err := fmt.Fprintf(w,
+		"\x1b_Ga=a,q=2,i=%d,r=1,z=%d\x1b\\\x1b_Ga=a,q=2,i=%d,s=3,v=1\x1b\\",
+		id, gap(0), id,
+	)
+	return err
+}
+
+func PixelResolution(f *os.File) (display.Resolution, error) {
+	t := termquery.New(f)
+	w, h, err := t.Size()
+	if err != nil {
+		return display.Resolution{}, err
+	}
+	cellWidth, cellHeight, err := t.CellSize()
+	if err != nil {
+		return display.Resolution{}, err
+	}
+
+	return display.Resolution{
+		Width:       w * cellWidth,
+		Height:      h * cellHeight,
+		WidthAlign:  cellWidth,
+		HeightAlign: cellHeight,
+	}, nil
+}
+
+// ClearScrollback deletes all images and clears screen and scrollback
+func ClearScrollback(w io.Writer) error {
+	if _, err := w.Write(passthrough.Detect().Wrap([]byte("\x1b_Ga=d,q=2\x1b\\"))); err != nil {
+		return err
+	}
+	return display.ClearScrollback(w)
+}
+
+// padCells returns m padded with transparent pixels to whole cells and
+// number of cells it covers, zero if cell size is not known. Padded so that
+// kitty does not have to scale the image to the cells.
+func padCells(m image.Image, r display.Resolution) (image.Image, int, int) {
+	if r.WidthAlign <= 0 || r.HeightAlign <= 0 {
+		return m, 0, 0
+	}
+	b := m.Bounds()
+	columns := (b.Dx() + r.WidthAlign - 1) / r.WidthAlign
+	rows := (b.Dy() + r.HeightAlign - 1) / r.HeightAlign
+	if b.Dx() == columns*r.WidthAlign && b.Dy() == rows*r.HeightAlign {
+		return m, columns, rows
+	}
+	pm := image.NewNRGBA(image.Rect(0, 0, columns*r.WidthAlign, rows*r.HeightAlign))
+	draw.Draw(pm, b.Sub(b.Min), m, b.Min, draw.Src)
+	return pm, columns, rows
+}
+
+type Display struct {
+	// ID of displayed images, zero for none
+	ID uint32
+}
+
+func (Display) Name() string                                           { return "kitty" }
+func (Display) Detect(f *os.File) bool                                 { return IsCompatible() }
+func (Display) PixelResolution(f *os.File) (display.Resolution, error) { return PixelResolution(f) }
+func (d Display) Image(w io.Writer, m image.Image, r display.Resolution) error {
+	m, columns, rows := padCells(m, r)
+	pw := passthrough.NewWriter(w, passthrough.Detect())
+	if err := ImageCells(pw, m, d.ID, columns, rows); err != nil {
+		return err
+	}
+	return pw.Flush()
+}
+func (Display) ClearScrollback(w io.Writer) error { return ClearScrollback(w) }
+func (Display) IsText() bool                      { return false }
+
+// Delete deletes displayed images, only possible with non-zero ID
+func (d Display) Delete(w io.Writer) error {
+	if d.ID == 0 {
+		return nil
+	}
+	pw := passthrough.NewWriter(w, passthrough.Detect())
+	if err := Delete(pw, d.ID); err != nil {
+		return err
+	}
+	return pw.Flush()
+}
+func (Display) Animation(w io.Writer, bs []byte, r display.Resolution) error {
+	g, err := gif.DecodeAll(bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	pw := passthrough.NewWriter(w, passthrough.Detect())
+	if err := Animation(pw, g); err != nil {
+		return err
+	}
+	return pw.Flush()
+}
@@ -0,0 +1,119 @@
+// Package passthrough wraps escape sequences so that terminal multiplexers
+// like tmux and GNU screen pass them to the outer terminal.
+package passthrough
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+type Multiplexer int
+
+const (
+	None Multiplexer = iota
+	Tmux
+	Screen
+)
+
+// tmux drops too large sequences, screen has a small buffer
+const (
+	tmuxChunkSize   = 256 * 1024
+	screenChunkSize = 768
+)
+
+// Detect multiplexer using environment
+func Detect() Multiplexer {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return Tmux
+	case os.Getenv("STY") != "":
+		return Screen
+	}
+	return None
+}
+
+func (m Multiplexer) String() string {
+	switch m {
+	case Tmux:
+		return "tmux"
+	case Screen:
+		return "screen"
+	}
+	return "none"
+}
+
+// Wrap bs in DCS passthrough sequences if needed.
+// tmux needs "allow-passthrough on" since 3.3.
+func (m Multiplexer) Wrap(bs []byte) []byte {
+	switch m {
+	case Tmux:
+		b := &bytes.Buffer{}
+		for _, c := range chunks(bs, tmuxChunkSize) {
+			b.WriteString("\x1bPtmux;")
+			// escapes inside the passthrough sequence has to be doubled
+			b.Write(bytes.ReplaceAll(c, []byte("\x1b"), []byte("\x1b\x1b")))
+			b.WriteString("\x1b\\")
+		}
+		return b.Bytes()
+	case Screen:
+		b := &bytes.Buffer{}
+		for _, c := range chunks(bs, screenChunkSize) {
+			b.WriteString("\x1bP")
+			b.Write(c)
+			b.WriteString("\x1b\\")
+		}
+		return b.Bytes()
+	}
+	return bs
+}
+
+func (m Multiplexer) WrapString(s string) string {
+	return string(m.Wrap([]byte(s)))
+}
+
+// chunks splits bs into chunks of at most size bytes. A string terminator
+// (ESC \) is split between chunks so that screen does not see it as the
+// end of its own DCS sequence.
+func chunks(bs []byte, size int) [][]byte {
+	var cs [][]byte
+	for len(bs) > 0 {
+		n := size
+		if n > len(bs) {
+			n = len(bs)
+		}
+		if i := bytes.Index(bs[:n], []byte("\x1b\\")); i != -1 {
+			n = i + 1
+		}
+		cs = append(cs, bs[:n])
+		bs = bs[n:]
+	}
+	return cs
+}
+
+// Writer buffers writes and wraps them on flush
+type Writer struct {
+	w   io.Writer
+	m   Multiplexer
+	buf bytes.Buffer
+}
+
+func NewWriter(w io.Writer, m Multiplexer) *Writer {
+	return &Writer{w: w, m: m}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.m == None {
+		return w.w.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *Writer) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.m.Wrap(w.buf.Bytes()))
+	w.buf.Reset()
+	return err
+}
@@ -0,0 +1,33 @@
+package passthrough_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/wader/ffcat/internal/passthrough"
+)
+
+func TestWrap(t *testing.T) {
+	testCases := []struct {
+		m        passthrough.Multiplexer
+		input    string
+		expected string
+	}{
+		{m: passthrough.None, input: "\x1b[c", expected: "\x1b[c"},
+		{m: passthrough.Tmux, input: "\x1b[c", expected: "\x1bPtmux;\x1b\x1b[c\x1b\\"},
+		{m: passthrough.Screen, input: "\x1b[c", expected: "\x1bP\x1b[c\x1b\\"},
+		{
+			m:        passthrough.Screen,
+			input:    "\x1bPq#0\x1b\\\x1b[c",
+			expected: "\x1bP\x1bPq#0\x1b\x1b\\\x1bP\\\x1b[c\x1b\\",
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			actual := tC.m.WrapString(tC.input)
+			if tC.expected != actual {
+				t.Errorf("expected %q, got %q", tC.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Package procgroup runs commands in their own process group so that the
+// whole process tree can be killed.
+package procgroup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Watch kills process group of p when ctx is done, call returned function
+// when process has exited to stop watching
+func Watch(ctx context.Context, p *os.Process) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = Kill(p)
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Output is like cmd.Output but kills the process group when ctx is done.
+// Stderr is included in the error if not set. Stdin is copied without
+// waiting for it to finish so that a process exiting early is not blocked
+// on the rest of the input.
+func Output(ctx context.Context, c *exec.Cmd) ([]byte, error) {
+	Set(c)
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr *bytes.Buffer
+	if c.Stderr == nil {
+		stderr = &bytes.Buffer{}
+		c.Stderr = stderr
+	}
+	var stdin io.Reader
+	var stdinw io.WriteCloser
+	if c.Stdin != nil {
+		if _, ok := c.Stdin.(*os.File); !ok {
+			stdin = c.Stdin
+			c.Stdin = nil
+			if stdinw, err = c.StdinPipe(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	stop := Watch(ctx, c.Process)
+	defer stop()
+
+	if stdinw != nil {
+		// pipe is closed by Wait, abandoned if still reading input
+		go func() {
+			_, _ = io.Copy(stdinw, stdin)
+			stdinw.Close()
+		}()
+	}
+
+	b := &bytes.Buffer{}
+	_, readErr := b.ReadFrom(stdout)
+	if err := c.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if stderr != nil {
+			var ee *exec.ExitError
+			if errors.As(err, &ee) {
+				ee.Stderr = stderr.Bytes()
+			}
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("%w: %s", err, msg)
+			}
+		}
+		return nil, err
+	}
+	return b.Bytes(), readErr
+}
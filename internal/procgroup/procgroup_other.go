@@ -0,0 +1,17 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package procgroup
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Set does nothing, no process groups
+func Set(c *exec.Cmd) {}
+
+// Kill only the process itself
+func Kill(p *os.Process) error {
+	return p.Kill()
+}
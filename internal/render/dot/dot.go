@@ -2,23 +2,33 @@ package dot
 
 import (
 	"bytes"
+	"context"
 	"image"
 	"os/exec"
-	"strings"
 
+	"github.com/wader/ffcat/internal/procgroup"
 	"github.com/wader/ffcat/internal/render"
+	"github.com/wader/ffcat/internal/sniff"
 )
 
 type Render struct{}
 
-func (Render) CanHandle(bs []byte) bool {
-	// TODO: improve
-	return strings.Contains(strings.ToLower(string(bs)), "digraph")
+func (Render) Name() string { return "dot" }
+
+func (Render) Probe(in render.Input) int {
+	switch {
+	case in.MIME == sniff.Graphviz:
+		return 100
+	case in.Ext() == ".dot", in.Ext() == ".gv":
+		return 50
+	}
+	return 0
 }
 
-func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (render.Output, error) {
-	c := exec.Command("dot", "-Gbgcolor=black", "-Gfontcolor=white", "-Ncolor=white", "-Nfontcolor=white", "-Ecolor=white", "-Efontcolor=white", "-Tpng", path)
-	bs, err := c.Output()
+func (Render) Output(ctx context.Context, in render.Input, rRes render.Resolution, rRange render.Range, rOpts render.Options) (render.Output, error) {
+	c := exec.CommandContext(ctx, "dot", "-Gbgcolor=black", "-Gfontcolor=white", "-Ncolor=white", "-Nfontcolor=white", "-Ecolor=white", "-Efontcolor=white", "-Tpng")
+	in.CmdInput(c)
+	bs, err := procgroup.Output(ctx, c)
 	if err != nil {
 		return Output{}, err
 	}
@@ -35,8 +45,9 @@ type Output struct {
 	i image.Image
 }
 
-func (o Output) String() string         { return "dot" }
-func (o Output) Images() []render.Image { return []render.Image{Image{i: o.i}} }
+func (o Output) String() string              { return "dot" }
+func (o Output) Images() <-chan render.Image { return render.Send(Image{i: o.i}) }
+func (o Output) Err() error                  { return nil }
 
 type Image struct{ i image.Image }
 
@@ -0,0 +1,133 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/gif"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+	"github.com/wader/ffcat/internal/render"
+)
+
+// max frame rate for animations, higher makes gif large and slow to display
+const animationMaxFPS = 15
+
+// animation renders range of video stream s as an animated GIF using a
+// generated palette
+func animation(ctx context.Context, file interface{}, pr goffmpeg.FFProbeResult, s goffmpeg.FFProbeStream, rRes render.Resolution, tr render.TimeRange) (render.Output, error) {
+	i := &goffmpeg.Input{
+		File: file,
+		Flags: []string{
+			"-ss", fmt.Sprintf("%f", tr.Offset),
+			"-t", fmt.Sprintf("%f", tr.Duration),
+		},
+	}
+
+	// fit in half of terminal height to not scroll away
+	width := int(s.DisplayWidth())
+	height := int(s.DisplayHeight())
+	if width > rRes.Width {
+		height = int(float32(height) / (float32(width) / float32(rRes.Width)))
+		width = rRes.Width
+	}
+	if maxHeight := rRes.Height / 2; maxHeight > 0 && height > maxHeight {
+		width = int(float32(width) / (float32(height) / float32(maxHeight)))
+		height = maxHeight
+	}
+
+	fg := goffmpeg.FilterGraph{
+		{
+			{
+				Name:   "fps",
+				Inputs: []string{fmt.Sprintf("0:%d", s.Index)},
+				Options: map[string]string{
+					"fps": fmt.Sprintf("min(source_fps,%d)", animationMaxFPS),
+				},
+			},
+			{
+				Name: "scale",
+				Options: map[string]string{
+					"width":  fmt.Sprintf("%d", width),
+					"height": fmt.Sprintf("%d", height),
+					"flags":  "lanczos",
+				},
+			},
+			{
+				Name: "split",
+				Options: map[string]string{
+					"outputs": "2",
+				},
+				Outputs: []string{"palette_in", "frames"},
+			},
+		},
+		{
+			{
+				Name:   "palettegen",
+				Inputs: []string{"palette_in"},
+				Options: map[string]string{
+					"stats_mode": "diff",
+				},
+				Outputs: []string{"palette"},
+			},
+		},
+		{
+			{
+				Name:   "paletteuse",
+				Inputs: []string{"frames", "palette"},
+				Options: map[string]string{
+					"dither": "bayer",
+				},
+				Outputs: []string{"out"},
+			},
+		},
+	}
+
+	bb := &bytes.Buffer{}
+
+	f := goffmpeg.FFmpegCmd{
+		Context:     ctx,
+		Inputs:      []*goffmpeg.Input{i},
+		FilterGraph: &fg,
+		Outputs: []*goffmpeg.Output{
+			{
+				Maps: []*goffmpeg.Map{
+					{
+						Specifier: "[out]",
+						Codec:     "gif",
+					},
+				},
+				Flags: []string{
+					"-loop", "0",
+				},
+				Format: "gif",
+				File:   bb,
+			},
+		},
+	}
+
+	if err := f.Run(); err != nil {
+		return nil, err
+	}
+
+	bs := bb.Bytes()
+	m, err := gif.Decode(bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Output{
+		pr: pr,
+		c:  render.Send(AnimationImage{i: Image{s: s, i: m}, gif: bs}),
+	}, nil
+}
+
+type AnimationImage struct {
+	i   Image
+	gif []byte
+}
+
+func (i AnimationImage) String() string     { return i.i.String() + " (animated)" }
+func (i AnimationImage) Image() image.Image { return i.i.Image() }
+func (i AnimationImage) GIF() []byte        { return i.gif }
@@ -0,0 +1,185 @@
+package ffmpeg
+
+import (
+	"fmt"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+	"github.com/wader/ffcat/internal/render"
+)
+
+// audioRows returns number of rows audio stream s is drawn as, each row gets
+// its own output so that rows are shown as soon as they are ready
+func audioRows(s goffmpeg.FFProbeStream, rOpts render.Options) int {
+	if rOpts.AudioCombined || s.Channels < 2 || (rOpts.Audio == render.AudioPhase && s.Channels == 2) {
+		return 1
+	}
+	return int(s.Channels)
+}
+
+// audioGraph returns filter chains that draws audio stream s to one output
+// per row, see audioRows, the height of each output and if only the last
+// frame should be used. A row is a channel, or all channels if combined, of
+// rowHeight for waveform and spectrogram.
+func audioGraph(s goffmpeg.FFProbeStream, selectExpr string, outs []string, width int, rowHeight int, duration float64, rOpts render.Options) (goffmpeg.FilterGraph, int, bool) {
+	prefix := fmt.Sprintf("audio%d_", s.Index)
+
+	aselect := goffmpeg.Filter{
+		Name:   "aselect",
+		Inputs: []string{fmt.Sprintf("0:%d", s.Index)},
+		Options: map[string]string{
+			"expr": selectExpr,
+		},
+	}
+	waves := func(inputs []string, output string) goffmpeg.Filter {
+		return goffmpeg.Filter{
+			Name:   "showwavespic",
+			Inputs: inputs,
+			Options: map[string]string{
+				"size":   fmt.Sprintf("%dx%d", width, rowHeight),
+				"colors": "white",
+			},
+			Outputs: []string{output},
+		}
+	}
+	spectrum := func(inputs []string, output string) []goffmpeg.Filter {
+		opts := rOpts.Spectrum
+		return []goffmpeg.Filter{
+			{
+				Name:   "showspectrumpic",
+				Inputs: inputs,
+				Options: map[string]string{
+					"size":     fmt.Sprintf("%dx%d", width, rowHeight),
+					"mode":     "combined",
+					"fscale":   stringOr(opts.Scale, "lin"),
+					"color":    stringOr(opts.Color, "intensity"),
+					"win_func": stringOr(opts.WinFunc, "hann"),
+					"legend":   fmt.Sprintf("%d", boolInt(opts.Legend)),
+				},
+			},
+			{
+				// legend adds axes around spectrum, scale to known size
+				Name: "scale",
+				Options: map[string]string{
+					"width":  fmt.Sprintf("%d", width),
+					"height": fmt.Sprintf("%d", rowHeight),
+				},
+				Outputs: []string{output},
+			},
+		}
+	}
+
+	if rOpts.Audio == render.AudioPhase && s.Channels == 2 {
+		fg, height := phaseGraph(aselect, prefix, outs[0], width, rowHeight*2, duration)
+		return fg, height, true
+	}
+
+	// source chain for each row, one mono chain per channel if more than
+	// one row
+	var sources []goffmpeg.FilterChain
+	var fg goffmpeg.FilterGraph
+	if len(outs) == 1 {
+		sources = append(sources, goffmpeg.FilterChain{aselect})
+	} else {
+		var splitOuts []string
+		for i := range outs {
+			splitOuts = append(splitOuts, fmt.Sprintf("%sc%d", prefix, i))
+		}
+		fg = append(fg, goffmpeg.FilterChain{
+			aselect,
+			{Name: "asplit", Options: map[string]string{"outputs": fmt.Sprintf("%d", len(outs))}, Outputs: splitOuts},
+		})
+		for i, c := range splitOuts {
+			sources = append(sources, goffmpeg.FilterChain{
+				{Name: "pan", Inputs: []string{c}, Options: map[string]string{"args": fmt.Sprintf("mono|c0=c%d", i)}},
+			})
+		}
+	}
+
+	for i, src := range sources {
+		switch rOpts.Audio {
+		case render.AudioSpectrum:
+			fg = append(fg, append(src, spectrum(nil, outs[i])...))
+		case render.AudioBoth:
+			// waveform and spectrogram stacked
+			wIn, sIn := fmt.Sprintf("%sw%d", prefix, i), fmt.Sprintf("%ss%d", prefix, i)
+			wOut, sOut := wIn+"out", sIn+"out"
+			fg = append(fg,
+				append(src, goffmpeg.Filter{Name: "asplit", Options: map[string]string{"outputs": "2"}, Outputs: []string{wIn, sIn}}),
+				goffmpeg.FilterChain{waves([]string{wIn}, wOut)},
+				spectrum([]string{sIn}, sOut),
+				goffmpeg.FilterChain{{Name: "vstack", Inputs: []string{wOut, sOut}, Options: map[string]string{"inputs": "2"}, Outputs: []string{outs[i]}}},
+			)
+		default:
+			fg = append(fg, append(src, waves(nil, outs[i])))
+		}
+	}
+
+	if rOpts.Audio == render.AudioBoth {
+		return fg, rowHeight * 2, false
+	}
+	return fg, rowHeight, false
+}
+
+// phaseGraph returns filter chains that draws a vectorscope and phase meter
+// of a stereo stream next to each other. Vectorscope accumulates over the
+// whole range and phase meter scrolls so that the range fits in height.
+func phaseGraph(aselect goffmpeg.Filter, prefix string, out string, width int, height int, duration float64) (goffmpeg.FilterGraph, int) {
+	if height > width/2 {
+		height = width / 2
+	}
+	rate := 1
+	if duration > 0 && float64(height)/duration > 1 {
+		rate = int(float64(height) / duration)
+	}
+
+	return goffmpeg.FilterGraph{
+		{
+			aselect,
+			{Name: "asplit", Options: map[string]string{"outputs": "2"}, Outputs: []string{prefix + "vs", prefix + "pm"}},
+		},
+		{
+			{
+				Name:   "avectorscope",
+				Inputs: []string{prefix + "vs"},
+				Options: map[string]string{
+					"size": fmt.Sprintf("%dx%d", height, height),
+					"mode": "lissajous",
+					"draw": "line",
+					// no fade to accumulate over range
+					"rf": "0",
+					"gf": "0",
+					"bf": "0",
+				},
+				Outputs: []string{prefix + "vsout"},
+			},
+		},
+		{
+			{
+				Name:   "aphasemeter",
+				Inputs: []string{prefix + "pm"},
+				Options: map[string]string{
+					"video": "1",
+					"size":  fmt.Sprintf("%dx%d", width-height, height),
+					"rate":  fmt.Sprintf("%d", rate),
+				},
+				Outputs: []string{prefix + "pmaudio", prefix + "pmout"},
+			},
+		},
+		{{Name: "anullsink", Inputs: []string{prefix + "pmaudio"}}},
+		{{Name: "hstack", Inputs: []string{prefix + "vsout", prefix + "pmout"}, Options: map[string]string{"inputs": "2"}, Outputs: []string{out}}},
+	}, height
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func stringOr(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -2,20 +2,143 @@ package ffmpeg
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/draw"
-	"os"
+	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wader/ffcat/internal/goffmpeg"
 	"github.com/wader/ffcat/internal/render"
+	"github.com/wader/ffcat/internal/sniff"
 )
 
 type Render struct{}
 
-func (Render) CanHandle(bs []byte) bool { return true }
+func (Render) Name() string { return "ffmpeg" }
+
+// probeTimeout limits ffprobe run by Probe for formats that are not sniffed
+const probeTimeout = 5 * time.Second
+
+// Probe is high for media types. Other types are asked ffprobe about as
+// ffmpeg might know about formats that are not sniffed, ex: subtitles and
+// playlists, 0 if it finds no streams.
+func (Render) Probe(in render.Input) int {
+	switch {
+	case in.MIME == sniff.SVG:
+		return 5
+	case strings.HasPrefix(in.MIME, "video/"),
+		strings.HasPrefix(in.MIME, "audio/"),
+		strings.HasPrefix(in.MIME, "image/"),
+		in.MIME == "application/ogg":
+		return 80
+	}
+
+	// path so that playlists can resolve relative paths, otherwise only
+	// the sniffed start
+	var file interface{} = in.Path
+	if in.Path == "" {
+		file = bytes.NewReader(in.Probe)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	fp := goffmpeg.FFProbeCmd{Context: ctx, Input: goffmpeg.Input{File: file}}
+	if err := fp.Run(); err != nil || len(fp.ProbeResult.Streams) == 0 {
+		return 0
+	}
+	return 10
+}
+
+// minTileWidth is smallest tile width before wrapping to more rows
+const minTileWidth = 128
+
+// minFitTileSize is smallest tile size in pixels when checking if tiles fit
+const minFitTileSize = 8
+
+// maxTiles returns number of tiles that fit in resolution, tiles are at least
+// minFitTileSize and one cell
+func maxTiles(r render.Resolution) int {
+	size := func(align int) int {
+		if align > minFitTileSize {
+			return align
+		}
+		return minFitTileSize
+	}
+	columns := r.Width / size(r.WidthAlign)
+	rows := r.Height / size(r.HeightAlign)
+	if columns < 1 {
+		columns = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return columns * rows
+}
+
+// gridLayout returns columns, rows and tile width for n tiles with aspect
+// ratio width/height to fit in width and height (zero if unknown). Zero
+// columns or rows are calculated, if both are zero the fewest rows that gives
+// tiles at least minTileWidth wide are used, otherwise the largest tiles.
+func gridLayout(n, columns, rows, width, height, maxTileWidth int, aspect float64) (int, int, int) {
+	tileWidth := func(c, r int) int {
+		w := width / c
+		if height > 0 {
+			if hw := int(float64(height/r) * aspect); hw < w {
+				w = hw
+			}
+		}
+		if maxTileWidth > 0 && w > maxTileWidth {
+			w = maxTileWidth
+		}
+		return w
+	}
+
+	switch {
+	case columns > 0 && rows > 0:
+	case columns > 0:
+		rows = (n + columns - 1) / columns
+	case rows > 0:
+		columns = (n + rows - 1) / rows
+	default:
+		best := 0
+		for r := 1; r <= n; r++ {
+			c := (n + r - 1) / r
+			if w := tileWidth(c, r); w > best {
+				columns, rows, best = c, r, w
+			}
+			if best >= minTileWidth {
+				break
+			}
+		}
+	}
+
+	return columns, rows, tileWidth(columns, rows)
+}
+
+// selectStreams returns streams matching any of the comma separated
+// specifiers in stream order
+func selectStreams(streams []goffmpeg.FFProbeStream, specs string) ([]goffmpeg.FFProbeStream, error) {
+	selected := map[uint]bool{}
+	for _, spec := range strings.Split(specs, ",") {
+		ss, err := goffmpeg.ParseStreamSpecifier(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss.Select(streams) {
+			selected[s.Index] = true
+		}
+	}
+	var r []goffmpeg.FFProbeStream
+	for _, s := range streams {
+		if selected[s.Index] {
+			r = append(r, s)
+		}
+	}
+	return r, nil
+}
 
 func isImageCodec(s string) bool {
 	switch s {
@@ -25,31 +148,55 @@ func isImageCodec(s string) bool {
 	return false
 }
 
-func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (render.Output, error) {
-	fp := goffmpeg.FFProbeCmd{Input: goffmpeg.Input{File: path}}
-	if err := fp.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
-		return nil, err
+func (Render) Output(ctx context.Context, in render.Input, rRes render.Resolution, rRange render.Range, rOpts render.Options) (render.Output, error) {
+	var probeFile, file interface{} = in.Path, in.Path
+	if in.Path == "" {
+		// ffprobe consumes the start of the stream, replay it to ffmpeg
+		probed := &bytes.Buffer{}
+		probeFile = io.TeeReader(in.Reader, probed)
+		file = io.MultiReader(probed, in.Reader)
 	}
 
-	if rRange.Offset < 0 {
-		rRange.Offset = fp.ProbeResult.Duration().Seconds() + rRange.Offset
+	fp := goffmpeg.FFProbeCmd{Context: ctx, Input: goffmpeg.Input{File: probeFile}}
+	if err := fp.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w", in.Name, err)
 	}
 
 	pr := fp.ProbeResult
 
+	if rOpts.Streams != "" {
+		ss, err := selectStreams(pr.Streams, rOpts.Streams)
+		if err != nil {
+			return nil, err
+		}
+		if len(ss) == 0 {
+			return nil, fmt.Errorf("%s: no streams matching %q", in.Name, rOpts.Streams)
+		}
+		pr.Streams = ss
+	}
+
 	if len(pr.Streams) == 1 && (pr.Format.FormatName == "image2" || pr.Duration() <= time.Microsecond*time.Duration(40)) {
 		// is an image case
 
 		s := pr.Streams[0]
 
 		i := &goffmpeg.Input{
-			File: path,
+			File: file,
 		}
 
+		displayWidth := int(s.DisplayWidth())
+		displayHeight := int(s.DisplayHeight())
+		if displayWidth == 0 || displayHeight == 0 {
+			return nil, fmt.Errorf("%s: unknown image size", in.Name)
+		}
+		// exact size is needed to read raw frame
 		width := rRes.Width
-		if width > int(s.Width) {
-			width = int(s.Width)
+		if width > displayWidth {
+			width = displayWidth
+		}
+		height := displayHeight * width / displayWidth
+		if height < 1 {
+			height = 1
 		}
 
 		fg := goffmpeg.FilterGraph(
@@ -60,7 +207,7 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 						Name:   "scale",
 						Options: map[string]string{
 							"width":  fmt.Sprintf("%d", width),
-							"height": "-1",
+							"height": fmt.Sprintf("%d", height),
 						},
 						Outputs: []string{"out"},
 					},
@@ -68,28 +215,17 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 			})
 
 		bb := &bytes.Buffer{}
+		o := goffmpeg.RawVideoOutput("[out]", bb)
+		o.Flags = append(o.Flags, "-frames", "1")
 
 		f := goffmpeg.FFmpegCmd{
+			Context: ctx,
 			// DebugLog:    log.New(os.Stderr, "debug>", 0),
 			// Stderr:      os.Stderr,
 			Inputs:      []*goffmpeg.Input{i},
 			FilterGraph: &fg,
-			Outputs: []*goffmpeg.Output{
-				{
-					Maps: []*goffmpeg.Map{
-						{
-							Specifier: "[out]",
-							Codec:     "png",
-						},
-					},
-					Flags: []string{
-						"-frames", "1",
-					},
-					Format: "image2",
-					File:   bb,
-				},
-			},
-			Flags: []string{
+			Outputs:     []*goffmpeg.Output{o},
+			Flags:       []string{
 				// "-v", "debug",
 				// "-copyts",
 			},
@@ -100,31 +236,83 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 			return nil, err
 		}
 
-		m, _, err := image.Decode(bb)
+		m, err := goffmpeg.RawVideoReader{R: bb, Width: width, Height: height}.ReadFrame()
 		if err != nil {
 			return nil, err
 		}
 
-		return Output{
+		return &Output{
 			pr: pr,
-			is: []render.Image{
-				Image{s: s, i: m},
-			},
+			c:  render.Send(Image{s: s, i: m}),
 		}, nil
 	}
 
-	frames := int(rRange.Duration / rRange.Delta)
+	// frame numbers are relative to first non-image video stream
+	fps := 0.0
+	for _, s := range pr.Streams {
+		if s.CodecType == "video" && !isImageCodec(s.CodecName) {
+			fps = s.FrameRate()
+			break
+		}
+	}
+	tr, err := rRange.Resolve(pr.Duration().Seconds(), fps)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", in.Name, err)
+	}
+
+	if rOpts.Animate {
+		if s, ok := pr.FindFirstStreamCodecType("video"); ok && !isImageCodec(s.CodecName) {
+			return animation(ctx, file, pr, s, rRes, tr)
+		}
+	}
+
+	frames := int(tr.Duration / tr.Delta)
+	var fs goffmpeg.FrameSelect
+	if rOpts.Frames != "" {
+		if fs, err = goffmpeg.ParseFrameSelect(rOpts.Frames); err != nil {
+			return nil, err
+		}
+		// number of tiles from range if selection count is not known
+		if n := fs.Count(); n > 0 {
+			frames = n
+		}
+	}
+	if rOpts.Columns > 0 && rOpts.Rows > 0 {
+		// fixed grid, spread frames evenly over range
+		frames = rOpts.Columns * rOpts.Rows
+		tr.Delta = tr.Duration / float64(frames)
+	}
+	// warning if selection has more frames than tiles
+	cutOffWarning := ""
+	if rOpts.Frames != "" && fs.Count() == 0 {
+		cutOffWarning = fmt.Sprintf("%s: frame selection %s has more than %d frames, change range or grid to show more", in.Name, fs, frames)
+	}
+	if n := maxTiles(rRes); frames > n {
+		return nil, fmt.Errorf("%d tiles don't fit in %dx%d, at most %d", frames, rRes.Width, rRes.Height, n)
+	}
+
+	vSelectExpr := fmt.Sprintf(`if(between(t,0,%f), if(isnan(prev_selected_t), 1, gte(t-prev_selected_t,%f)))`, tr.Duration, tr.Delta)
+	if e := fs.Expr(); e != "" {
+		vSelectExpr = fmt.Sprintf(`between(t,0,%f)*(%s)`, tr.Duration, e)
+	}
 
 	i := &goffmpeg.Input{
-		File: path,
+		File: file,
 		Flags: []string{
-			"-ss", fmt.Sprintf("%f", rRange.Offset),
-			"-t", fmt.Sprintf("%f", rRange.Duration),
+			"-ss", fmt.Sprintf("%f", tr.Offset),
+			"-t", fmt.Sprintf("%f", tr.Duration),
 		},
 	}
 
+	scopes, err := ParseScopes(rOpts.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
 	maxStreamHeight := uint(0)
 	maxStreamWidth := uint(0)
+	columns := frames
+	rows := 1
 	tileWidth := 320
 	tileHeight := 200
 	audioChannelHeight := 100
@@ -140,19 +328,27 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 		}
 	}
 	if maxStreamHeight != 0 && maxStreamWidth != 0 {
-		tileWidth = rRes.Width / frames
-		tileHeight = int(float32(maxStreamHeight) / (float32(maxStreamWidth) / float32(tileWidth)))
+		aspect := float64(maxStreamWidth) / float64(maxStreamHeight)
+		// scopes are stacked below each tile in same size
+		tileAspect := aspect / float64(1+len(scopes))
+		columns, rows, tileWidth = gridLayout(frames, rOpts.Columns, rOpts.Rows, rRes.Width, rRes.Height, rOpts.MaxTileWidth, tileAspect)
+		tileHeight = int(float64(tileWidth) / aspect)
 	}
 
 	// align sizes to cursor cell size
 	tileWidth -= tileWidth % rRes.WidthAlign
 	tileHeight -= tileHeight % rRes.HeightAlign
 	audioChannelHeight -= audioChannelHeight % rRes.HeightAlign
+	if tileWidth < rRes.WidthAlign {
+		tileWidth = rRes.WidthAlign
+	}
+	if tileHeight < rRes.HeightAlign {
+		tileHeight = rRes.HeightAlign
+	}
 
-	charAlignedWidth := tileWidth * frames
+	charAlignedWidth := tileWidth * columns
 
 	var fg goffmpeg.FilterGraph
-	var outs []string
 
 	subtitleStreamCount := 0
 	for _, s := range pr.Streams {
@@ -160,105 +356,125 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 			subtitleStreamCount++
 		}
 	}
-	subtitleStreamIndex := 0
+	subtitleStreamIndex := -1
 	if subtitleStreamCount > 0 {
 		for _, s := range pr.Streams {
-			if s.CodecType == "video" {
+			if s.CodecType == "video" && !isImageCodec(s.CodecName) {
 				subtitleStreamIndex = int(s.Index)
 				break
 			}
 		}
+		if subtitleStreamIndex == -1 {
+			// no video selected to draw subtitles on, use a blank strip
+			var splitOuts []string
+			for i := 0; i < subtitleStreamCount; i++ {
+				splitOuts = append(splitOuts, fmt.Sprintf("subtitle_video%d", i))
+			}
+			fg = append(fg, goffmpeg.FilterChain{
+				{
+					Name: "color",
+					Options: map[string]string{
+						"color":    "black",
+						"size":     fmt.Sprintf("%dx%d", charAlignedWidth, tileHeight*rows),
+						"duration": fmt.Sprintf("%f", tr.Duration),
+					},
+				},
+				{
+					Name: "split",
+					Options: map[string]string{
+						"outputs": fmt.Sprintf("%d", len(splitOuts)),
+					},
+					Outputs: splitOuts,
+				},
+			})
+		}
 	}
 
-	vSelectExpr := fmt.Sprintf(`if(between(t,0,%f), if(isnan(prev_selected_t), 1, gte(t-prev_selected_t,%f)))`, rRange.Duration, rRange.Delta)
-	aSelectExpr := fmt.Sprintf(`between(t,0,%f)`, rRange.Duration)
+	aSelectExpr := fmt.Sprintf(`between(t,0,%f)`, tr.Duration)
 
 	subtitleOutCount := 0
 
+	// each stream has its own output of known size
+	var sos []*streamOutput
+	// loudness graph outputs by stream index, summary is added when done
+	loudnessOutputs := map[uint]*streamOutput{}
+
 	for _, s := range pr.Streams {
-		o := fmt.Sprintf("out%d", len(outs))
+		so := &streamOutput{
+			s:       s,
+			slot:    len(sos),
+			out:     fmt.Sprintf("out%d", len(sos)),
+			tiles:   1,
+			columns: 1,
+		}
+
 		if s.CodecType == "audio" {
-			for channel := uint(0); channel < s.Channels; channel++ {
-				fg = append(fg, goffmpeg.FilterChain{
-					{
-						Name:   "aselect",
-						Inputs: []string{fmt.Sprintf("0:%d", s.Index)},
-						Options: map[string]string{
-							"expr": aSelectExpr,
-						},
-					},
-					{
-						Name: "pan",
-						Options: map[string]string{
-							"args": fmt.Sprintf("mono|c0=c%d", channel),
-						},
-					},
-					{
-						Name: "showwavespic",
-						Options: map[string]string{
-							"size":           fmt.Sprintf("%dx%d", charAlignedWidth, audioChannelHeight),
-							"split_channels": "1",
-							"colors":         "white",
-						},
-					},
-					// colorspace filter wants even size
-					{
-						Name: "pad",
-						Options: map[string]string{
-							"width":  "iw+mod(iw,2)",
-							"height": "ih+mod(ih,2)",
-						},
-					},
-					// make sure all outputs has same colorspace as vstack seems to pick the first
-					{
-						Name: "colorspace",
-						Options: map[string]string{
-							"iall": "bt709",
-							"all":  "bt709",
-							"trc":  "srgb",
-						},
-						Outputs: []string{o},
-					},
+			if s.Channels == 0 {
+				continue
+			}
+			// one output per row, first one is so
+			rowOutputs := []*streamOutput{so}
+			for i := 1; i < audioRows(s, rOpts); i++ {
+				rowOutputs = append(rowOutputs, &streamOutput{
+					s:       s,
+					slot:    len(sos) + i,
+					out:     fmt.Sprintf("out%d", len(sos)+i),
+					tiles:   1,
+					columns: 1,
 				})
-				outs = append(outs, o)
 			}
+			var outs []string
+			for _, ro := range rowOutputs {
+				outs = append(outs, ro.out)
+			}
+			afg, height, last := audioGraph(s, aSelectExpr, outs, charAlignedWidth, audioChannelHeight, tr.Duration, rOpts)
+			fg = append(fg, afg...)
+			for i, ro := range rowOutputs {
+				ro.width, ro.height, ro.last = charAlignedWidth, height, last
+				if len(rowOutputs) > 1 {
+					ro.info = fmt.Sprintf("channel %d", i)
+				}
+			}
+			sos = append(sos, rowOutputs[:len(rowOutputs)-1]...)
+			so = rowOutputs[len(rowOutputs)-1]
 		} else if s.CodecType == "video" {
 			if isImageCodec(s.CodecName) {
 				width := int(s.DisplayWidth())
 				height := int(s.DisplayHeight())
+				if width == 0 || height == 0 {
+					continue
+				}
 				if width > charAlignedWidth {
 					height = int(float32(height) / (float32(width) / float32(charAlignedWidth)))
-					height += height % 2
 					width = charAlignedWidth
 				}
+				if height < 1 {
+					height = 1
+				}
+				so.width = width
+				so.height = height
 				fg = append(fg, goffmpeg.FilterChain{
 					{
-						Name: "scale",
+						Name:   "scale",
+						Inputs: []string{fmt.Sprintf("0:%d", s.Index)},
 						Options: map[string]string{
 							"width":  fmt.Sprintf("%d", width),
 							"height": fmt.Sprintf("%d", height),
 						},
-					},
-					{
-						Name: "colorspace",
-						Options: map[string]string{
-							"iall": "bt709",
-							"all":  "bt709",
-							"trc":  "srgb",
-						},
-						Outputs: []string{o},
+						Outputs: []string{so.out},
 					},
 				})
-				outs = append(outs, o)
 			} else {
-				splitOuts := []string{o}
-				if s.Index == uint(subtitleStreamIndex) {
-					for i := 0; i < subtitleStreamCount; i++ {
-						splitOuts = append(splitOuts, fmt.Sprintf("subtitle_video%d", i))
-					}
+				so.width = tileWidth
+				so.height = tileHeight * (1 + len(scopes))
+				so.tiles = frames
+				so.cutOffWarning = cutOffWarning
+				so.columns = columns
+				tileOut := so.out
+				if len(scopes) > 0 {
+					tileOut = fmt.Sprintf("video%d_tile", s.Index)
 				}
-
-				fg = append(fg, goffmpeg.FilterChain{
+				chain := goffmpeg.FilterChain{
 					{
 						Name:   "select",
 						Inputs: []string{fmt.Sprintf("0:%d", s.Index)},
@@ -276,7 +492,7 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 					{
 						Name: "drawtext",
 						Options: map[string]string{
-							"text":      fmt.Sprintf("%%{pts\\:hms\\:%f}", rRange.Offset),
+							"text":      fmt.Sprintf("%%{pts\\:hms\\:%f}", tr.Offset),
 							"x":         "0",
 							"y":         "h-text_h",
 							"fontcolor": "white",
@@ -284,41 +500,102 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 							"box":       "1",
 							"boxcolor":  "black@0.5",
 						},
+						Outputs: []string{tileOut},
 					},
-					{
-						Name: "tile",
-						Options: map[string]string{
-							"layout":    fmt.Sprintf("%dx%d", frames, 1),
-							"nb_frames": fmt.Sprintf("%d", frames),
-						},
-					},
-					{
-						Name: "pad",
-						Options: map[string]string{
-							"width":  "iw+mod(iw,2)",
-							"height": "ih+mod(ih,2)",
+				}
+
+				if rOpts.FrameInfo {
+					if in.Path == "" {
+						return nil, fmt.Errorf("%s: frame info needs a file", in.Name)
+					}
+					var sfs *goffmpeg.FrameSelect
+					if rOpts.Frames != "" {
+						sfs = &fs
+					}
+					pfs, ns, err := probeFrames(ctx, in.Path, pr, s, tr, sfs, frames)
+					if err != nil {
+						return nil, fmt.Errorf("%s: %w", in.Name, err)
+					}
+					// select exactly the probed frames so tiles and info match
+					chain[0].Options["expr"] = frameNumbersExpr(ns)
+					drawPTS := chain[len(chain)-1]
+					chain = append(append(chain[:len(chain)-1], frameInfoFilters(pfs)...), drawPTS)
+				}
+
+				if len(scopes) > 0 {
+					// scopes are drawn from selected frames before scaling
+					selectOuts := []string{fmt.Sprintf("video%d_select", s.Index)}
+					stackIns := []string{tileOut}
+					for i, sc := range scopes {
+						in := fmt.Sprintf("video%d_scope%d", s.Index, i)
+						out := in + "out"
+						selectOuts = append(selectOuts, in)
+						stackIns = append(stackIns, out)
+						fg = append(fg, scopeChain(sc, in, out, tileWidth, tileHeight))
+					}
+					fg = append(fg,
+						goffmpeg.FilterChain{
+							chain[0],
+							{
+								Name: "split",
+								Options: map[string]string{
+									"outputs": fmt.Sprintf("%d", len(selectOuts)),
+								},
+								Outputs: selectOuts,
+							},
 						},
-					},
-					{
-						Name: "colorspace",
-						Options: map[string]string{
-							"iall": "bt709",
-							"all":  "bt709",
-							"trc":  "srgb",
+						goffmpeg.FilterChain{
+							{
+								Name:    "vstack",
+								Inputs:  stackIns,
+								Options: map[string]string{"inputs": fmt.Sprintf("%d", len(stackIns))},
+								Outputs: []string{so.out},
+							},
 						},
-					},
-					{
+					)
+					chain = chain[1:]
+					chain[0].Inputs = selectOuts[:1]
+				}
+
+				if int(s.Index) == subtitleStreamIndex {
+					// subtitles are drawn on a strip of the same size
+					stripOut := fmt.Sprintf("strip%d", len(sos))
+					chain[len(chain)-1].Outputs = nil
+					chain = append(chain, goffmpeg.Filter{
 						Name: "split",
 						Options: map[string]string{
-							"outputs": fmt.Sprintf("%d", len(splitOuts)),
+							"outputs": "2",
 						},
-						Outputs: splitOuts,
-					},
-				})
-				outs = append(outs, o)
-			}
+						Outputs: []string{tileOut, stripOut},
+					})
 
+					var splitOuts []string
+					for i := 0; i < subtitleStreamCount; i++ {
+						splitOuts = append(splitOuts, fmt.Sprintf("subtitle_video%d", i))
+					}
+					fg = append(fg, goffmpeg.FilterChain{
+						{
+							Name:   "tile",
+							Inputs: []string{stripOut},
+							Options: map[string]string{
+								"layout":    fmt.Sprintf("%dx%d", columns, rows),
+								"nb_frames": fmt.Sprintf("%d", frames),
+							},
+						},
+						{
+							Name: "split",
+							Options: map[string]string{
+								"outputs": fmt.Sprintf("%d", len(splitOuts)),
+							},
+							Outputs: splitOuts,
+						},
+					})
+				}
+				fg = append(fg, chain)
+			}
 		} else if s.CodecType == "subtitle" {
+			so.width = charAlignedWidth
+			so.height = tileHeight * rows
 			sbo := fmt.Sprintf("subtitle_main%d", subtitleOutCount)
 			fg = append(fg, goffmpeg.FilterChain{
 				{
@@ -337,63 +614,70 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 					Name:    "overlay",
 					Inputs:  []string{sbo, fmt.Sprintf("0:%d", s.Index)},
 					Options: map[string]string{},
-					Outputs: []string{o},
+					Outputs: []string{so.out},
 				},
 			})
 			subtitleOutCount++
-			outs = append(outs, o)
+		} else {
+			continue
 		}
-	}
 
-	// vstack require > 1 inputs
-	if len(outs) > 1 {
-		fg = append(fg, goffmpeg.FilterChain{
-			{
-				Name:   "vstack",
-				Inputs: outs,
-				Options: map[string]string{
-					"inputs": fmt.Sprintf(`%d`, len(outs)),
-				},
-				Outputs: []string{"out"},
-			},
-		})
-	} else {
-		fg = append(fg, goffmpeg.FilterChain{
-			{
-				Name:    "copy",
-				Inputs:  outs,
-				Outputs: []string{"out"},
-			},
-		})
+		sos = append(sos, so)
+
+		if s.CodecType == "audio" && rOpts.Loudness {
+			lo := &streamOutput{
+				s:       s,
+				slot:    len(sos),
+				out:     fmt.Sprintf("out%d", len(sos)),
+				tiles:   1,
+				columns: 1,
+				last:    true,
+				width:   charAlignedWidth,
+			}
+			lo.height = loudnessHeight(lo.width, audioChannelHeight)
+			lo.height -= lo.height % rRes.HeightAlign
+			if lo.height < rRes.HeightAlign {
+				lo.height = rRes.HeightAlign
+			}
+			fg = append(fg, loudnessGraph(s, aSelectExpr, lo.out, lo.width, lo.height, rOpts.LoudnessTarget)...)
+			loudnessOutputs[s.Index] = lo
+			sos = append(sos, lo)
+		}
 	}
 
-	bb := &bytes.Buffer{}
+	if len(sos) == 0 {
+		return nil, fmt.Errorf("%s: no streams to render", in.Name)
+	}
 
 	f := goffmpeg.FFmpegCmd{
+		Context: ctx,
 		// DebugLog: log.New(os.Stderr, "debug>", 0),
 		// Stderr:      os.Stderr,
 		Inputs:      []*goffmpeg.Input{i},
 		FilterGraph: &fg,
-		Outputs: []*goffmpeg.Output{
-			{
-				Maps: []*goffmpeg.Map{
-					{
-						Specifier: "[out]",
-						Codec:     "png",
-					},
-				},
-				Flags: []string{
-					"-frames", "1",
-				},
-				Format: "image2",
-				File:   bb,
-			},
-		},
-		Flags: []string{
+		Flags:       []string{
 			// "-v", "debug",
 			// "-copyts",
 		},
 	}
+	for _, so := range sos {
+		so.r, so.w = io.Pipe()
+		o := goffmpeg.RawVideoOutput("["+so.out+"]", so.w)
+		if !so.last {
+			tiles := so.tiles
+			if so.cutOffWarning != "" {
+				// one more to know if cut off
+				tiles++
+			}
+			o.Flags = append(o.Flags, "-frames", fmt.Sprintf("%d", tiles))
+		}
+		f.Outputs = append(f.Outputs, o)
+	}
+	var ll *loudnessLog
+	if len(loudnessOutputs) > 0 {
+		ll = newLoudnessLog()
+		f.Stderr = ll
+	}
 
 	// if *debugFlag {
 	// f.Stderr = os.Stderr
@@ -401,68 +685,188 @@ func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (
 
 	// log.Printf("var: %s\n", strings.Join(f.Args(), " "))
 
-	err := f.Run()
-	if err != nil {
-		return nil, err
-	}
+	updates := make(chan update)
+	c := make(chan render.Image)
+	o := &Output{pr: pr, c: c}
+
+	go func() {
+		defer close(c)
+		orderedDone := make(chan struct{})
+		go func() {
+			ordered(ctx, len(sos), updates, c)
+			close(orderedDone)
+		}()
+		o.err = func() error {
+			defer close(updates)
+
+			var wg sync.WaitGroup
+			readErrs := make([]error, len(sos))
+			for i, so := range sos {
+				wg.Add(1)
+				go func(i int, so *streamOutput) {
+					defer wg.Done()
+					readErrs[i] = so.read(updates)
+				}(i, so)
+			}
 
-	m, _, err := image.Decode(bb)
-	if err != nil {
-		return nil, err
+			err := f.Run()
+			if ll != nil {
+				for i, l := range ll.loudness {
+					if so, ok := loudnessOutputs[i]; ok {
+						so.info = "loudness " + l.String()
+					}
+				}
+			}
+			for _, so := range sos {
+				so.w.CloseWithError(err)
+			}
+			wg.Wait()
+			if err != nil {
+				return err
+			}
+			for _, err := range readErrs {
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}()
+		<-orderedDone
+	}()
+
+	return o, nil
+}
+
+// streamOutput reads raw frames of a stream output and places them as tiles
+// in a grid, row by row
+type streamOutput struct {
+	s       goffmpeg.FFProbeStream
+	slot    int
+	out     string
+	tiles   int
+	columns int
+	width   int
+	height  int
+	// warning if there are more frames than tiles
+	cutOffWarning string
+	// last only keeps last frame, ex: a graph that is updated over time
+	last bool
+	// info is added to final image description
+	info string
+
+	r *io.PipeReader
+	w *io.PipeWriter
+}
+
+// read sends a partial image for each completed row of tiles if there are
+// more tiles to come and a final when done
+func (so *streamOutput) read(updates chan<- update) error {
+	cutOff := false
+	rows := (so.tiles + so.columns - 1) / so.columns
+	m := image.NewNRGBA(image.Rect(0, 0, so.width*so.columns, so.height*rows))
+	draw.Draw(m, m.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	rr := goffmpeg.RawVideoReader{R: so.r, Width: so.width, Height: so.height}
+	for n := 0; ; n++ {
+		f, err := rr.ReadFrame()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			// make ffmpeg fail instead of blocking on write
+			so.r.CloseWithError(err)
+			return err
+		}
+		if so.last {
+			draw.Draw(m, f.Bounds(), f, f.Bounds().Min, draw.Src)
+			continue
+		}
+		if n >= so.tiles {
+			cutOff = true
+			continue
+		}
+		pos := image.Point{X: (n % so.columns) * so.width, Y: (n / so.columns) * so.height}
+		draw.Draw(m, f.Bounds().Add(pos), f, f.Bounds().Min, draw.Src)
+		// each partial is encoded by the display so only send full rows
+		if n == so.tiles-1 || n%so.columns != so.columns-1 {
+			continue
+		}
+		pm := image.NewNRGBA(m.Bounds())
+		copy(pm.Pix, m.Pix)
+		updates <- update{slot: so.slot, image: Image{s: so.s, i: pm, partial: true}}
+	}
+	im := Image{s: so.s, i: m, info: so.info}
+	if cutOff {
+		im.warning = so.cutOffWarning
 	}
+	updates <- update{slot: so.slot, image: im}
 
-	var is []render.Image
-	dy := 0
-	for _, s := range pr.Streams {
-		height := 0
+	return nil
+}
 
-		if s.CodecType == "audio" {
-			height = audioChannelHeight * int(s.Channels)
-		} else if s.CodecType == "video" {
-			if isImageCodec(s.CodecName) {
-				width := int(s.DisplayWidth())
-				height = int(s.DisplayHeight())
-				if width > charAlignedWidth {
-					height = int(float32(height) / (float32(width) / float32(charAlignedWidth)))
-					height += height % 2
-				}
+type update struct {
+	slot  int
+	image render.Image
+}
 
-			} else {
-				height = tileHeight
+func isFinal(i render.Image) bool {
+	p, ok := i.(render.Partial)
+	return !ok || !p.Partial()
+}
+
+// ordered sends images in slot order, images for later slots are held back
+// until all slots before are final, only the latest one is kept
+func ordered(ctx context.Context, n int, updates <-chan update, c chan<- render.Image) {
+	latest := make([]render.Image, n)
+	sent := make([]bool, n)
+	head := 0
+	send := func(slot int) {
+		sent[slot] = true
+		select {
+		case c <- latest[slot]:
+		case <-ctx.Done():
+		}
+	}
+	for u := range updates {
+		latest[u.slot], sent[u.slot] = u.image, false
+		if u.slot != head {
+			continue
+		}
+		send(head)
+		for head < n && latest[head] != nil && isFinal(latest[head]) {
+			head++
+			if head < n && latest[head] != nil {
+				send(head)
 			}
-		} else if s.CodecType == "subtitle" {
-			height = tileHeight
 		}
-
-		if height != 0 {
-			r := image.Rectangle{Max: image.Point{X: charAlignedWidth, Y: height}}
-			ci := image.NewNRGBA(r)
-			draw.Draw(ci, r, m, image.Point{X: 0, Y: dy}, draw.Over)
-			is = append(is, Image{s: s, i: ci})
-			dy += r.Max.Y
+	}
+	// slots that never got an image
+	for ; head < n; head++ {
+		if latest[head] != nil && !sent[head] {
+			send(head)
 		}
 	}
-
-	return Output{
-		pr: pr,
-		is: is,
-	}, nil
 }
 
 type Output struct {
-	pr goffmpeg.FFProbeResult
-	is []render.Image
+	pr  goffmpeg.FFProbeResult
+	c   <-chan render.Image
+	err error
 }
 
-func (o Output) String() string {
+func (o *Output) String() string {
 	return fmt.Sprintf("%s: %ds", o.pr.FormatName(), o.pr.Duration()/time.Second)
 }
 
-func (o Output) Images() []render.Image { return o.is }
+func (o *Output) Images() <-chan render.Image { return o.c }
+func (o *Output) Err() error                  { return o.err }
 
 type Image struct {
-	s goffmpeg.FFProbeStream
-	i image.Image
+	s       goffmpeg.FFProbeStream
+	i       image.Image
+	partial bool
+	info    string
+	warning string
 }
 
 func (i Image) String() string {
@@ -481,8 +885,13 @@ func (i Image) String() string {
 	} else if s.CodecType == "subtitle" {
 		ss = append(ss, fmt.Sprintf("%s", s.Tags.Language))
 	}
+	if i.info != "" {
+		ss = append(ss, " "+i.info)
+	}
 
 	return strings.Join(ss, "")
 }
 
 func (i Image) Image() image.Image { return i.i }
+func (i Image) Partial() bool      { return i.partial }
+func (i Image) Warning() string    { return i.warning }
@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+	"github.com/wader/ffcat/internal/render"
+)
+
+func TestGridLayout(t *testing.T) {
+	testCases := []struct {
+		name           string
+		n              int
+		columns        int
+		rows           int
+		width          int
+		height         int
+		maxTileWidth   int
+		aspect         float64
+		expectedLayout string
+	}{
+		{name: "fixed grid", n: 10, columns: 3, rows: 2, width: 1200, height: 600, aspect: 16.0 / 9, expectedLayout: "3x2 400"},
+		{name: "columns only", n: 10, columns: 4, width: 1200, height: 600, aspect: 16.0 / 9, expectedLayout: "4x3 300"},
+		{name: "rows only", n: 10, rows: 2, width: 1200, height: 600, aspect: 16.0 / 9, expectedLayout: "5x2 240"},
+		{name: "height limited", n: 4, columns: 4, width: 1200, height: 100, aspect: 2, expectedLayout: "4x1 200"},
+		{name: "auto fit min tile width", n: 10, width: 1200, height: 600, aspect: 16.0 / 9, expectedLayout: "5x2 240"},
+		{name: "auto fit largest tiles", n: 10, width: 500, expectedLayout: "3x4 166"},
+		{name: "max tile width", n: 4, width: 1200, maxTileWidth: 200, expectedLayout: "4x1 200"},
+		{name: "max tile width fixed grid", n: 4, columns: 2, rows: 2, width: 1200, height: 1200, maxTileWidth: 200, aspect: 1, expectedLayout: "2x2 200"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			c, r, w := gridLayout(tC.n, tC.columns, tC.rows, tC.width, tC.height, tC.maxTileWidth, tC.aspect)
+			actualLayout := fmt.Sprintf("%dx%d %d", c, r, w)
+			if tC.expectedLayout != actualLayout {
+				t.Errorf("expected %q, got %q", tC.expectedLayout, actualLayout)
+			}
+		})
+	}
+}
+
+func TestMaxTiles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		r        render.Resolution
+		expected int
+	}{
+		{name: "cells", r: render.Resolution{Width: 800, Height: 480, WidthAlign: 10, HeightAlign: 20}, expected: 1920},
+		{name: "pixels", r: render.Resolution{Width: 1920, Height: 1080, WidthAlign: 1, HeightAlign: 1}, expected: 32400},
+		{name: "smaller than a tile", r: render.Resolution{Width: 4, Height: 4, WidthAlign: 1, HeightAlign: 1}, expected: 1},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			actual := maxTiles(tC.r)
+			if tC.expected != actual {
+				t.Errorf("expected %d, got %d", tC.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	// "1:2" final image 2 for slot 1, "0:1~" partial image 1 for slot 0
+	testCases := []struct {
+		name     string
+		n        int
+		updates  []string
+		expected []string
+	}{
+		{name: "in order", n: 2, updates: []string{"0:1", "1:2"}, expected: []string{"1", "2"}},
+		{name: "later slot held back", n: 2, updates: []string{"1:2", "0:1"}, expected: []string{"1", "2"}},
+		{name: "partials for head", n: 2, updates: []string{"1:4", "0:1~", "0:2~", "0:3"}, expected: []string{"1~", "2~", "3", "4"}},
+		{name: "only latest held back", n: 2, updates: []string{"1:2~", "1:3", "0:1"}, expected: []string{"1", "3"}},
+		{name: "partial head followed by final", n: 3, updates: []string{"0:1~", "2:4", "1:3", "0:2"}, expected: []string{"1~", "2", "3", "4"}},
+		{name: "head never final", n: 2, updates: []string{"0:1~", "1:2"}, expected: []string{"1~", "2"}},
+		{name: "head without image", n: 2, updates: []string{"1:2"}, expected: []string{"2"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			updates := make(chan update, len(tC.updates))
+			for _, u := range tC.updates {
+				var slot int
+				var id uint
+				if _, err := fmt.Sscanf(u, "%d:%d", &slot, &id); err != nil {
+					t.Fatal(err)
+				}
+				updates <- update{
+					slot:  slot,
+					image: Image{s: goffmpeg.FFProbeStream{Index: id}, partial: strings.HasSuffix(u, "~")},
+				}
+			}
+			close(updates)
+
+			c := make(chan render.Image, len(tC.updates))
+			ordered(context.Background(), tC.n, updates, c)
+			close(c)
+
+			var actual []string
+			for i := range c {
+				id := fmt.Sprintf("%d", i.(Image).s.Index)
+				if !isFinal(i) {
+					id += "~"
+				}
+				actual = append(actual, id)
+			}
+			if fmt.Sprint(tC.expected) != fmt.Sprint(actual) {
+				t.Errorf("expected %v, got %v", tC.expected, actual)
+			}
+		})
+	}
+}
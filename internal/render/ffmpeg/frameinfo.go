@@ -0,0 +1,128 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+	"github.com/wader/ffcat/internal/render"
+)
+
+// tile border colors by picture type
+var pictTypeColors = map[string]string{
+	"I": "red",
+	"P": "green",
+	"B": "blue",
+}
+
+const frameInfoBorder = 3
+
+// probeFrames probes frames of video stream s in range and returns the frames
+// that will be tiles. Frames are selected the same way as the select filter
+// using either time delta or fs, at most maxFrames. Frame numbers are index
+// into returned numbers.
+func probeFrames(ctx context.Context, path string, pr goffmpeg.FFProbeResult, s goffmpeg.FFProbeStream, tr render.TimeRange, fs *goffmpeg.FrameSelect, maxFrames int) ([]goffmpeg.FFProbeFrame, []int, error) {
+	startTime, _ := strconv.ParseFloat(pr.Format.StartTime, 64)
+	fp := goffmpeg.FFProbeCmd{
+		Context:       ctx,
+		Input:         goffmpeg.Input{File: path},
+		ShowFrames:    true,
+		SelectStreams: fmt.Sprintf("%d", s.Index),
+		// seeks to keyframe before, frames before offset are skipped below
+		ReadIntervals: fmt.Sprintf("%f%%+%f", startTime+tr.Offset, tr.Duration),
+	}
+	if err := fp.Run(); err != nil {
+		return nil, nil, err
+	}
+
+	var frames []goffmpeg.FFProbeFrame
+	var ns []int
+	n := 0
+	prevSelected := -1.0
+	for _, f := range fp.ProbeResult.Frames {
+		if len(frames) >= maxFrames {
+			break
+		}
+		// same as t in select filter after seek
+		t := f.Time() - startTime - tr.Offset
+		if t < 0 {
+			continue
+		}
+		if t > tr.Duration {
+			break
+		}
+
+		var selected bool
+		if fs != nil {
+			var ok bool
+			if selected, ok = fs.Match(n, f); !ok {
+				return nil, nil, fmt.Errorf("%s: frame info can't be used with frame selection that depends on content", fs)
+			}
+		} else {
+			selected = prevSelected < 0 || t-prevSelected >= tr.Delta
+		}
+		if selected {
+			frames = append(frames, f)
+			ns = append(ns, n)
+			prevSelected = t
+		}
+		n++
+	}
+
+	return frames, ns, nil
+}
+
+// frameNumbersExpr returns select expression for frame numbers
+func frameNumbersExpr(ns []int) string {
+	if len(ns) == 0 {
+		return "0"
+	}
+	var exprs []string
+	for _, n := range ns {
+		exprs = append(exprs, fmt.Sprintf("eq(n,%d)", n))
+	}
+	return strings.Join(exprs, "+")
+}
+
+// frameInfoFilters returns filters that annotates tile i with frame type,
+// key flag, size and timestamps and draws a border colored by picture type
+func frameInfoFilters(frames []goffmpeg.FFProbeFrame) []goffmpeg.Filter {
+	var filters []goffmpeg.Filter
+	for i, f := range frames {
+		enable := fmt.Sprintf("eq(n,%d)", i)
+		key := ""
+		if f.KeyFrame == 1 {
+			key = " key"
+		}
+		color := pictTypeColors[f.PictType]
+		if color == "" {
+			color = "gray"
+		}
+		filters = append(filters,
+			goffmpeg.Filter{
+				Name: "drawbox",
+				Options: map[string]string{
+					"color":     color,
+					"thickness": fmt.Sprintf("%d", frameInfoBorder),
+					"enable":    enable,
+				},
+			},
+			goffmpeg.Filter{
+				Name: "drawtext",
+				Options: map[string]string{
+					"text":      fmt.Sprintf("%s%s %sB\npts %d dts %d", f.PictType, key, f.PktSize, f.Pts, f.PktDts),
+					"x":         fmt.Sprintf("%d", frameInfoBorder),
+					"y":         fmt.Sprintf("%d", frameInfoBorder),
+					"fontcolor": "white",
+					"shadowy":   "1",
+					"box":       "1",
+					"boxcolor":  "black@0.5",
+					"enable":    enable,
+				},
+			},
+		)
+	}
+	return filters
+}
@@ -0,0 +1,186 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+)
+
+// ebur128 can't draw graphs smaller than this
+const ebur128Width = 640
+const ebur128Height = 480
+
+// loudness summary of an audio stream, nearFullScale is number of samples
+// within 1 dB of full scale (volumedetect histogram_0db)
+type loudness struct {
+	integrated    float64
+	lra           float64
+	truePeak      float64
+	nearFullScale int
+}
+
+func (l loudness) String() string {
+	return fmt.Sprintf("integrated %.1f LUFS, range %.1f LU, true peak %.1f dBFS, %d samples within 1 dB of full scale",
+		l.integrated, l.lra, l.truePeak, l.nearFullScale)
+}
+
+// loudnessHeight returns height of loudness graph for width, rowHeight is
+// audio channel height
+func loudnessHeight(width int, rowHeight int) int {
+	height := width * 3 / 4
+	if height > rowHeight*3 {
+		height = rowHeight * 3
+	}
+	if height < 1 {
+		height = 1
+	}
+	return height
+}
+
+// loudnessGraph returns filter chains that draws ebur128 momentary and
+// short-term loudness graph of audio stream s to out. Summaries are logged
+// by filters named by stream index, see loudnessLog.
+func loudnessGraph(s goffmpeg.FFProbeStream, selectExpr string, out string, width int, height int, target int) goffmpeg.FilterGraph {
+	prefix := fmt.Sprintf("loudness%d_", s.Index)
+	// draw in same aspect ratio and scale down to keep text readable, a too
+	// narrow graph is squeezed instead
+	graphWidth := width * ebur128Height / height
+	if graphWidth < ebur128Width {
+		graphWidth = ebur128Width
+	}
+
+	return goffmpeg.FilterGraph{
+		{
+			{
+				Name:   "aselect",
+				Inputs: []string{fmt.Sprintf("0:%d", s.Index)},
+				Options: map[string]string{
+					"expr": selectExpr,
+				},
+			},
+			{
+				Name: "asplit",
+				Options: map[string]string{
+					"outputs": "2",
+				},
+				Outputs: []string{prefix + "ebur128", prefix + "volume"},
+			},
+		},
+		{
+			{
+				Name:   fmt.Sprintf("ebur128@loudness%d", s.Index),
+				Inputs: []string{prefix + "ebur128"},
+				Options: map[string]string{
+					"video":  "1",
+					"size":   fmt.Sprintf("%dx%d", graphWidth, ebur128Height),
+					"meter":  "18",
+					"peak":   "true",
+					"target": fmt.Sprintf("%d", target),
+				},
+				Outputs: []string{prefix + "graph", prefix + "audio"},
+			},
+		},
+		{
+			{
+				Name:   "anullsink",
+				Inputs: []string{prefix + "audio"},
+			},
+		},
+		{
+			{
+				Name:   "scale",
+				Inputs: []string{prefix + "graph"},
+				Options: map[string]string{
+					"width":  fmt.Sprintf("%d", width),
+					"height": fmt.Sprintf("%d", height),
+				},
+				Outputs: []string{out},
+			},
+		},
+		{
+			{
+				Name:   fmt.Sprintf("volumedetect@volume%d", s.Index),
+				Inputs: []string{prefix + "volume"},
+			},
+			{
+				Name: "anullsink",
+			},
+		},
+	}
+}
+
+// log prefix of filters named in loudnessGraph, ffmpeg names parsed filters
+// "Parsed_ebur128_3@loudness1", ex: "[Parsed_ebur128_3@loudness1 @ 0x55d0c8] "
+var loudnessPrefixRe = regexp.MustCompile(`^\[(?:[^]@ ]*@)?(loudness|volume)(\d+) @ [^]]*\] `)
+var loudnessValueRe = regexp.MustCompile(`^\s*(I|LRA|Peak|histogram_0db):\s+(\S+)`)
+
+// loudnessLog is a stderr writer that parses ebur128 and volumedetect
+// summaries line by line, only parsed values are kept. Summary lines after
+// the first are not prefixed with filter name.
+type loudnessLog struct {
+	partial     []byte
+	loudness    map[uint]*loudness
+	current     *loudness
+	currentKind string
+}
+
+func newLoudnessLog() *loudnessLog {
+	return &loudnessLog{loudness: map[uint]*loudness{}}
+}
+
+func (ll *loudnessLog) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// stats lines end with \r
+		i := bytes.IndexAny(p, "\r\n")
+		if i == -1 {
+			ll.partial = append(ll.partial, p...)
+			break
+		}
+		ll.line(string(append(ll.partial, p[:i]...)))
+		ll.partial = ll.partial[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (ll *loudnessLog) line(line string) {
+	if len(line) > 0 && line[0] == '[' {
+		ll.current = nil
+		if sm := loudnessPrefixRe.FindStringSubmatch(line); sm != nil {
+			n, _ := strconv.ParseUint(sm[2], 10, 64)
+			if ll.loudness[uint(n)] == nil {
+				ll.loudness[uint(n)] = &loudness{}
+			}
+			ll.current, ll.currentKind = ll.loudness[uint(n)], sm[1]
+			line = line[len(sm[0]):]
+		}
+	}
+	if ll.current == nil {
+		return
+	}
+	vm := loudnessValueRe.FindStringSubmatch(line)
+	if vm == nil {
+		return
+	}
+	switch {
+	case ll.currentKind == "volume" && vm[1] == "histogram_0db":
+		ll.current.nearFullScale, _ = strconv.Atoi(vm[2])
+	case ll.currentKind == "loudness":
+		v, err := strconv.ParseFloat(vm[2], 64)
+		if err != nil {
+			return
+		}
+		switch vm[1] {
+		case "I":
+			ll.current.integrated = v
+		case "LRA":
+			ll.current.lra = v
+		case "Peak":
+			ll.current.truePeak = v
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+)
+
+func TestLoudnessLog(t *testing.T) {
+	stderr := `Input #0, matroska,webm, from 'test.mkv':
+  Duration: 00:00:10.00, start: 0.000000, bitrate: 136 kb/s
+[Parsed_ebur128_3@loudness1 @ 0x55d0c8f4a2c0] t: 0.1        TARGET:-23 LUFS    M:-120.7 S:-120.7     I: -70.0 LUFS       LRA:   0.0 LU  FTPK: -inf dBFS  TPK: -inf dBFS
+[Parsed_ebur128_3@loudness1 @ 0x55d0c8f4a2c0] t: 0.2        TARGET:-23 LUFS    M:-120.7 S:-120.7     I: -70.0 LUFS       LRA:   0.0 LU  FTPK: -inf dBFS  TPK: -inf dBFS
+[Parsed_volumedetect_5@volume1 @ 0x55d0c8f4b400] n_samples: 882000
+[Parsed_volumedetect_5@volume1 @ 0x55d0c8f4b400] mean_volume: -20.3 dB
+[Parsed_volumedetect_5@volume1 @ 0x55d0c8f4b400] max_volume: 0.0 dB
+[Parsed_volumedetect_5@volume1 @ 0x55d0c8f4b400] histogram_0db: 12
+[Parsed_volumedetect_5@volume1 @ 0x55d0c8f4b400] histogram_1db: 40
+[Parsed_ebur128_3@loudness1 @ 0x55d0c8f4a2c0] Summary:
+
+  Integrated loudness:
+    I:         -18.4 LUFS
+    Threshold: -28.7 LUFS
+
+  Loudness range:
+    LRA:         6.1 LU
+    Threshold: -38.9 LUFS
+    LRA low:   -22.3 LUFS
+    LRA high:  -16.2 LUFS
+
+  True peak:
+    Peak:       -0.4 dBFS
+[out#0/rawvideo @ 0x55d0c8f1e100] video:1200kB audio:0kB subtitle:0kB other streams:0kB global headers:0kB muxing overhead: 0.000000%
+    I:         -99.0 LUFS
+`
+
+	ll := newLoudnessLog()
+	if _, err := ll.Write([]byte(stderr)); err != nil {
+		t.Fatal(err)
+	}
+	actual := ll.loudness
+	if len(actual) != 1 || actual[1] == nil {
+		t.Fatalf("expected loudness for stream 1, got %v", actual)
+	}
+	expected := loudness{integrated: -18.4, lra: 6.1, truePeak: -0.4, nearFullScale: 12}
+	if expected != *actual[1] {
+		t.Errorf("expected %q, got %q", expected, *actual[1])
+	}
+}
+
+func TestLoudnessLogSplitWrites(t *testing.T) {
+	ll := newLoudnessLog()
+	for _, s := range []string{
+		"frame=  1 fps=0.0 q=-0.0 size=N/A\r",
+		"[Parsed_volumedetect_5@volume2 @ 0x55d0c8f4b400] histo",
+		"gram_0db: 3\n",
+	} {
+		_, _ = ll.Write([]byte(s))
+	}
+	if ll.loudness[2] == nil || ll.loudness[2].nearFullScale != 3 {
+		t.Errorf("expected 3 samples near full scale for stream 2, got %v", ll.loudness)
+	}
+}
+
+func TestLoudnessGraphNarrow(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		size          string
+	}{
+		{width: 800, height: 480, size: "800x480"},
+		{width: 80, height: 96, size: "640x480"},
+		{width: 8, height: 20, size: "640x480"},
+	} {
+		var size string
+		for _, fc := range loudnessGraph(goffmpeg.FFProbeStream{Index: 1}, "1", "out", tc.width, tc.height, -23) {
+			for _, f := range fc {
+				if strings.HasPrefix(f.Name, "ebur128") {
+					size = f.Options["size"]
+				}
+			}
+		}
+		if size != tc.size {
+			t.Errorf("%dx%d: expected ebur128 size %s, got %s", tc.width, tc.height, tc.size, size)
+		}
+	}
+}
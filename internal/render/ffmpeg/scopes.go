@@ -0,0 +1,90 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wader/ffcat/internal/goffmpeg"
+)
+
+// scopeFilters are video scopes by name, drawn from the full size frame
+var scopeFilters = map[string]goffmpeg.Filter{
+	// luma levels per column with legal range graticule
+	"waveform": {
+		Name: "waveform",
+		Options: map[string]string{
+			"intensity": "0.1",
+			"graticule": "green",
+			"flags":     "numbers+dots",
+		},
+	},
+	"histogram": {
+		Name: "histogram",
+		Options: map[string]string{
+			"display_mode": "overlay",
+		},
+	},
+	"vectorscope": {
+		Name: "vectorscope",
+		Options: map[string]string{
+			"mode":      "color3",
+			"graticule": "green",
+			"flags":     "name",
+		},
+	},
+}
+
+// ParseScopes parses comma separated video scope names
+func ParseScopes(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	scopes := strings.Split(s, ",")
+	for _, sc := range scopes {
+		if _, ok := scopeFilters[sc]; !ok {
+			return nil, fmt.Errorf("invalid scope %q, should be waveform, histogram or vectorscope", sc)
+		}
+	}
+	return scopes, nil
+}
+
+// scopeChain returns filter chain that draws scope from input to output
+// scaled to width and height. Vectorscope keeps its aspect ratio.
+func scopeChain(scope string, input string, output string, width int, height int) goffmpeg.FilterChain {
+	f := scopeFilters[scope]
+	f.Inputs = []string{input}
+
+	if scope == "vectorscope" && width > height {
+		return goffmpeg.FilterChain{
+			f,
+			{
+				Name: "scale",
+				Options: map[string]string{
+					"width":  fmt.Sprintf("%d", height),
+					"height": fmt.Sprintf("%d", height),
+				},
+			},
+			{
+				Name: "pad",
+				Options: map[string]string{
+					"width":  fmt.Sprintf("%d", width),
+					"height": fmt.Sprintf("%d", height),
+					"x":      "(ow-iw)/2",
+				},
+				Outputs: []string{output},
+			},
+		}
+	}
+
+	return goffmpeg.FilterChain{
+		f,
+		{
+			Name: "scale",
+			Options: map[string]string{
+				"width":  fmt.Sprintf("%d", width),
+				"height": fmt.Sprintf("%d", height),
+			},
+			Outputs: []string{output},
+		},
+	}
+}
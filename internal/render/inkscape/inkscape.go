@@ -2,11 +2,13 @@ package inkscape
 
 import (
 	"bytes"
+	"context"
 	"image"
 	"os/exec"
-	"strings"
 
+	"github.com/wader/ffcat/internal/procgroup"
 	"github.com/wader/ffcat/internal/render"
+	"github.com/wader/ffcat/internal/sniff"
 )
 
 var Paths = []string{
@@ -25,19 +27,27 @@ func findPath(ss []string) (string, bool) {
 
 type Render struct{}
 
-func (Render) CanHandle(bs []byte) bool {
+func (Render) Name() string { return "inkscape" }
+
+func (Render) Probe(in render.Input) int {
 	if _, ok := findPath(Paths); !ok {
-		return false
+		return 0
+	}
+	switch {
+	case in.MIME == sniff.SVG:
+		return 90
+	case in.Ext() == ".svg":
+		return 50
 	}
-	// TODO: improve
-	return strings.Contains(strings.ToLower(string(bs)), "<svg")
+	return 0
 }
 
-func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (render.Output, error) {
+func (Render) Output(ctx context.Context, in render.Input, rRes render.Resolution, rRange render.Range, rOpts render.Options) (render.Output, error) {
 	p, _ := findPath(Paths)
 
-	c := exec.Command(p, "--pipe", "--export-type=png", "-o", "-", path)
-	bs, err := c.Output()
+	c := exec.CommandContext(ctx, p, "--export-type=png", "-o", "-")
+	in.CmdInput(c, "--pipe")
+	bs, err := procgroup.Output(ctx, c)
 	if err != nil {
 		return Output{}, err
 	}
@@ -54,8 +64,9 @@ type Output struct {
 	i image.Image
 }
 
-func (o Output) String() string         { return "svg" }
-func (o Output) Images() []render.Image { return []render.Image{Image{i: o.i}} }
+func (o Output) String() string              { return "svg" }
+func (o Output) Images() <-chan render.Image { return render.Send(Image{i: o.i}) }
+func (o Output) Err() error                  { return nil }
 
 type Image struct{ i image.Image }
 
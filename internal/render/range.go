@@ -0,0 +1,294 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Unit int
+
+const (
+	Second Unit = iota
+	Frame
+	Percent
+)
+
+// Time is seconds, frame number or percent of duration. Negative offsets
+// are relative to the end.
+type Time struct {
+	Value float64
+	Unit  Unit
+}
+
+func Seconds(s float64) Time { return Time{Value: s, Unit: Second} }
+
+func (t Time) String() string {
+	switch t.Unit {
+	case Frame:
+		return "#" + strconv.FormatFloat(t.Value, 'f', -1, 64)
+	case Percent:
+		return strconv.FormatFloat(t.Value, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatFloat(t.Value, 'f', -1, 64) + "s"
+}
+
+// seconds resolves time, duration and fps are zero if unknown
+func (t Time) seconds(duration float64, fps float64) (float64, error) {
+	switch t.Unit {
+	case Frame:
+		if fps <= 0 {
+			return 0, fmt.Errorf("%s: frame number needs a video stream with known frame rate", t)
+		}
+		return t.Value / fps, nil
+	case Percent:
+		if duration <= 0 {
+			return 0, fmt.Errorf("%s: percent needs known duration", t)
+		}
+		return duration * t.Value / 100, nil
+	}
+	return t.Value, nil
+}
+
+// Range to render, End is used instead of Duration if set
+type Range struct {
+	Offset   Time
+	End      *Time
+	Duration Time
+	Delta    Time
+}
+
+var DefaultRange = Range{
+	Offset:   Seconds(0),
+	Duration: Seconds(5),
+	Delta:    Seconds(1),
+}
+
+// TimeRange is a resolved Range in seconds
+type TimeRange struct {
+	Offset   float64
+	Duration float64
+	Delta    float64
+}
+
+// position resolves t, negative is relative to end
+func position(t Time, duration float64, fps float64) (float64, error) {
+	s, err := t.seconds(duration, fps)
+	if err != nil {
+		return 0, err
+	}
+	if t.Value < 0 {
+		if duration <= 0 {
+			return 0, fmt.Errorf("%s: seek from end needs known duration", t)
+		}
+		s += duration
+		if s < 0 {
+			s = 0
+		}
+	}
+	return s, nil
+}
+
+// Resolve range to seconds, duration and fps are zero if unknown
+func (r Range) Resolve(duration float64, fps float64) (TimeRange, error) {
+	var tr TimeRange
+	var err error
+
+	if tr.Offset, err = position(r.Offset, duration, fps); err != nil {
+		return TimeRange{}, err
+	}
+	if duration > 0 && tr.Offset >= duration {
+		return TimeRange{}, fmt.Errorf("offset %s is after end %s", r.Offset, Seconds(duration))
+	}
+
+	if r.End != nil {
+		end, err := position(*r.End, duration, fps)
+		if err != nil {
+			return TimeRange{}, err
+		}
+		if end <= tr.Offset {
+			return TimeRange{}, fmt.Errorf("end %s is before offset %s", *r.End, r.Offset)
+		}
+		tr.Duration = end - tr.Offset
+	} else {
+		if tr.Duration, err = r.Duration.seconds(duration, fps); err != nil {
+			return TimeRange{}, err
+		}
+		if tr.Duration <= 0 {
+			return TimeRange{}, fmt.Errorf("duration %s has to be positive", r.Duration)
+		}
+	}
+
+	if tr.Delta, err = r.Delta.seconds(duration, fps); err != nil {
+		return TimeRange{}, err
+	}
+	if tr.Delta <= 0 {
+		return TimeRange{}, fmt.Errorf("delta %s has to be positive", r.Delta)
+	}
+	if tr.Delta > tr.Duration {
+		tr.Delta = tr.Duration
+	}
+
+	return tr, nil
+}
+
+// ParseRange parses start[-end][,delta[,duration]], missing parts are
+// from DefaultRange. See ParseTime for time syntax.
+func ParseRange(s string) (Range, error) {
+	r := DefaultRange
+	parts := strings.Split(s, ",")
+	if len(parts) > 3 {
+		return Range{}, fmt.Errorf("%s: too many parts, should be start[-end][,delta[,duration]]", s)
+	}
+
+	startEnd := parts[0]
+	// skip sign of start
+	from := 0
+	if strings.HasPrefix(startEnd, "-") {
+		from = 1
+	}
+	if i := strings.Index(startEnd[from:], "-"); i != -1 {
+		i += from
+		end, err := ParseTime(startEnd[i+1:])
+		if err != nil {
+			return Range{}, fmt.Errorf("end: %w", err)
+		}
+		r.End = &end
+		startEnd = startEnd[:i]
+	}
+	var err error
+	if r.Offset, err = ParseTime(startEnd); err != nil {
+		return Range{}, fmt.Errorf("start: %w", err)
+	}
+
+	if len(parts) > 1 {
+		if r.Delta, err = ParseTime(parts[1]); err != nil {
+			return Range{}, fmt.Errorf("delta: %w", err)
+		}
+		if r.Delta.Value <= 0 {
+			return Range{}, fmt.Errorf("delta: %s has to be positive", parts[1])
+		}
+	}
+	if len(parts) > 2 {
+		if r.End != nil {
+			return Range{}, fmt.Errorf("%s: both end and duration", s)
+		}
+		if r.Duration, err = ParseTime(parts[2]); err != nil {
+			return Range{}, fmt.Errorf("duration: %w", err)
+		}
+		if r.Duration.Value <= 0 {
+			return Range{}, fmt.Errorf("duration: %s has to be positive", parts[2])
+		}
+	}
+
+	return r, nil
+}
+
+var unitSeconds = map[string]float64{
+	"h":  60 * 60,
+	"m":  60,
+	"s":  1,
+	"ms": 0.001,
+}
+
+// ParseTime parses time, can be negative:
+// 12.5 seconds
+// 1m30s 500ms 2h units h, m, s and ms
+// 1:02:03.5 [[hh:]mm:]ss[.frac]
+// #120 frame number
+// 50% percent of duration
+func ParseTime(s string) (Time, error) {
+	v := strings.TrimSpace(s)
+	sign := 1.0
+	if strings.HasPrefix(v, "-") {
+		sign = -1
+		v = v[1:]
+	}
+	if v == "" {
+		return Time{}, fmt.Errorf("%q: empty time", s)
+	}
+
+	switch {
+	case strings.HasPrefix(v, "#"):
+		n, err := strconv.ParseUint(v[1:], 10, 64)
+		if err != nil {
+			return Time{}, fmt.Errorf("%q: invalid frame number", s)
+		}
+		return Time{Value: sign * float64(n), Unit: Frame}, nil
+	case strings.HasSuffix(v, "%"):
+		n, err := parseNumber(v[:len(v)-1])
+		if err != nil || n > 100 {
+			return Time{}, fmt.Errorf("%q: invalid percent", s)
+		}
+		return Time{Value: sign * n, Unit: Percent}, nil
+	case strings.Contains(v, ":"):
+		parts := strings.Split(v, ":")
+		if len(parts) > 3 {
+			return Time{}, fmt.Errorf("%q: invalid timestamp, should be [[hh:]mm:]ss[.frac]", s)
+		}
+		t := 0.0
+		for i, p := range parts {
+			isLast := i == len(parts)-1
+			var n float64
+			var err error
+			if isLast {
+				n, err = parseNumber(p)
+			} else {
+				var u uint64
+				u, err = strconv.ParseUint(p, 10, 64)
+				n = float64(u)
+			}
+			// minutes and seconds after first part has to be < 60
+			if err != nil || (i > 0 && n >= 60) {
+				return Time{}, fmt.Errorf("%q: invalid timestamp, should be [[hh:]mm:]ss[.frac]", s)
+			}
+			t = t*60 + n
+		}
+		return Seconds(sign * t), nil
+	}
+
+	// number with optional unit, can be repeated like 1m30s
+	t := 0.0
+	for rest := v; rest != ""; {
+		i := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i == -1 {
+			if rest != v {
+				return Time{}, fmt.Errorf("%q: number without unit", s)
+			}
+			i = len(rest)
+		}
+		n, err := parseNumber(rest[:i])
+		if err != nil {
+			return Time{}, fmt.Errorf("%q: invalid number", s)
+		}
+		rest = rest[i:]
+		j := strings.IndexFunc(rest, func(r rune) bool { return r >= '0' && r <= '9' || r == '.' })
+		if j == -1 {
+			j = len(rest)
+		}
+		unit := rest[:j]
+		rest = rest[j:]
+		u := 1.0
+		if unit != "" {
+			var ok bool
+			if u, ok = unitSeconds[unit]; !ok {
+				return Time{}, fmt.Errorf("%q: unknown unit %q, should be h, m, s or ms", s, unit)
+			}
+		}
+		t += n * u
+	}
+
+	return Seconds(sign * t), nil
+}
+
+func parseNumber(s string) (float64, error) {
+	if s == "" || strings.ContainsAny(s, "+-eE") {
+		return 0, fmt.Errorf("invalid number")
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid number")
+	}
+	return n, nil
+}
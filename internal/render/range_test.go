@@ -0,0 +1,114 @@
+package render_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/wader/ffcat/internal/render"
+)
+
+func TestParseTime(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    render.Time
+		expectedErr string
+	}{
+		{input: "123", expected: render.Seconds(123)},
+		{input: "12.5", expected: render.Seconds(12.5)},
+		{input: "1:2", expected: render.Seconds(62)},
+		{input: "1:2:3", expected: render.Seconds(3723)},
+		{input: "1:2:3.1", expected: render.Seconds(3723.1)},
+		{input: "-10ms", expected: render.Seconds(-0.01)},
+		{input: "1m30s", expected: render.Seconds(90)},
+		{input: "2h", expected: render.Seconds(7200)},
+		{input: "1.5m", expected: render.Seconds(90)},
+		{input: "-20", expected: render.Seconds(-20)},
+		{input: "#120", expected: render.Time{Value: 120, Unit: render.Frame}},
+		{input: "-#10", expected: render.Time{Value: -10, Unit: render.Frame}},
+		{input: "50%", expected: render.Time{Value: 50, Unit: render.Percent}},
+		{input: "", expectedErr: `"": empty time`},
+		{input: "abc", expectedErr: `"abc": invalid number`},
+		{input: "10x", expectedErr: `"10x": unknown unit "x", should be h, m, s or ms`},
+		{input: "1m30", expectedErr: `"1m30": number without unit`},
+		{input: "1:60", expectedErr: `"1:60": invalid timestamp, should be [[hh:]mm:]ss[.frac]`},
+		{input: "1:2:3:4", expectedErr: `"1:2:3:4": invalid timestamp, should be [[hh:]mm:]ss[.frac]`},
+		{input: "1.5:2", expectedErr: `"1.5:2": invalid timestamp, should be [[hh:]mm:]ss[.frac]`},
+		{input: "#1.5", expectedErr: `"#1.5": invalid frame number`},
+		{input: "101%", expectedErr: `"101%": invalid percent`},
+		{input: "1e3", expectedErr: `"1e3": unknown unit "e", should be h, m, s or ms`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.input, func(t *testing.T) {
+			actual, err := render.ParseTime(tC.input)
+			if tC.expectedErr != "" {
+				if err == nil || err.Error() != tC.expectedErr {
+					t.Errorf("expected error %q, got %v", tC.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tC.expected != actual {
+				t.Errorf("expected %v, got %v", tC.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRangeResolve(t *testing.T) {
+	testCases := []struct {
+		input       string
+		duration    float64
+		fps         float64
+		expected    render.TimeRange
+		expectedErr string
+	}{
+		{input: "0", duration: 60, expected: render.TimeRange{Offset: 0, Duration: 5, Delta: 1}},
+		{input: "10,0.5,2", duration: 60, expected: render.TimeRange{Offset: 10, Duration: 2, Delta: 0.5}},
+		{input: "-10ms,0.1", duration: 60, expected: render.TimeRange{Offset: 59.99, Duration: 5, Delta: 0.1}},
+		{input: "-20", duration: 60, expected: render.TimeRange{Offset: 40, Duration: 5, Delta: 1}},
+		{input: "-10s,1,10", duration: 60, expected: render.TimeRange{Offset: 50, Duration: 10, Delta: 1}},
+		{input: "1:00-1:30,10", duration: 120, expected: render.TimeRange{Offset: 60, Duration: 30, Delta: 10}},
+		{input: "-30--10,5", duration: 60, expected: render.TimeRange{Offset: 30, Duration: 20, Delta: 5}},
+		{input: "50%,10%,20%", duration: 100, expected: render.TimeRange{Offset: 50, Duration: 20, Delta: 10}},
+		{input: "#50-#100,#25", fps: 25, expected: render.TimeRange{Offset: 2, Duration: 2, Delta: 1}},
+		{input: "0,10,2", duration: 60, expected: render.TimeRange{Offset: 0, Duration: 2, Delta: 2}},
+		{input: "10", expected: render.TimeRange{Offset: 10, Duration: 5, Delta: 1}},
+		{input: "-10", expectedErr: "-10s: seek from end needs known duration"},
+		{input: "50%", expectedErr: "50%: percent needs known duration"},
+		{input: "#10", duration: 60, expectedErr: "#10: frame number needs a video stream with known frame rate"},
+		{input: "70", duration: 60, expectedErr: "offset 70s is after end 60s"},
+		{input: "20-10", duration: 60, expectedErr: "end 10s is before offset 20s"},
+		{input: "1,2,3,4", expectedErr: "1,2,3,4: too many parts, should be start[-end][,delta[,duration]]"},
+		{input: "1-2,1,3", expectedErr: "1-2,1,3: both end and duration"},
+		{input: "1,0", expectedErr: "delta: 0 has to be positive"},
+		{input: "1,1,-1", expectedErr: "duration: -1 has to be positive"},
+		{input: "x", expectedErr: `start: "x": invalid number`},
+		{input: "1-x", expectedErr: `end: "x": invalid number`},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.input, func(t *testing.T) {
+			actual, err := func() (render.TimeRange, error) {
+				r, err := render.ParseRange(tC.input)
+				if err != nil {
+					return render.TimeRange{}, err
+				}
+				return r.Resolve(tC.duration, tC.fps)
+			}()
+			if tC.expectedErr != "" {
+				if err == nil || err.Error() != tC.expectedErr {
+					t.Errorf("expected error %q, got %v", tC.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// compare with some rounding
+			if fmt.Sprintf("%.6f", tC.expected) != fmt.Sprintf("%.6f", actual) {
+				t.Errorf("expected %v, got %v", tC.expected, actual)
+			}
+		})
+	}
+}
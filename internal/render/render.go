@@ -1,7 +1,13 @@
 package render
 
 import (
+	"context"
+	"fmt"
 	"image"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
 type Resolution struct {
@@ -11,15 +17,90 @@ type Resolution struct {
 	HeightAlign int
 }
 
-type Range struct {
-	Offset   float64
-	Duration float64
-	Delta    float64
+type Options struct {
+	// Animate video instead of showing frames
+	Animate bool
+	// Streams is comma separated ffmpeg stream specifiers, empty for all
+	Streams string
+	// Frames is video frame selection instead of time delta, see
+	// goffmpeg.ParseFrameSelect, empty for time delta
+	Frames string
+	// Columns and Rows of video tile grid, zero to fit resolution
+	Columns int
+	Rows    int
+	// MaxTileWidth limits video tile width, zero for no limit
+	MaxTileWidth int
+	// Audio is how to show audio, AudioWave if empty. AudioPhase is
+	// vectorscope and phase meter for stereo streams, waveform for others
+	Audio string
+	// AudioCombined shows all channels in one row instead of one per channel
+	AudioCombined bool
+	// Spectrum options for AudioSpectrum and AudioBoth
+	Spectrum Spectrum
+	// Loudness shows EBU R128 loudness graph below audio
+	Loudness bool
+	// LoudnessTarget is target level in LUFS for loudness graph
+	LoudnessTarget int
+	// Scopes is comma separated video scopes to show below each tile,
+	// waveform, histogram or vectorscope
+	Scopes string
+	// FrameInfo annotates video tiles with picture type, key frame, size and
+	// timestamps, needs Input.Path
+	FrameInfo bool
+}
+
+const (
+	AudioWave     = "wave"
+	AudioSpectrum = "spectrum"
+	AudioBoth     = "both"
+	AudioPhase    = "phase"
+)
+
+// Spectrum options, empty strings for defaults
+type Spectrum struct {
+	// Scale is frequency scale, lin or log
+	Scale string
+	// Color map, ex: intensity, rainbow, magma
+	Color string
+	// WinFunc is window function, ex: hann, hamming, blackman
+	WinFunc string
+	// Legend draws frequency and time axes
+	Legend bool
+}
+
+// Input to render, Reader reads the whole input including the Probe bytes.
+// Path is set if input is a regular file, renderers that need to seek can use
+// it instead of Reader. MIME is sniffed from Probe.
+type Input struct {
+	Name   string
+	Path   string
+	MIME   string
+	Probe  []byte
+	Reader io.Reader
+}
+
+// CmdInput makes c read input, path is used if possible so that relative
+// paths and hrefs in the file resolve, otherwise stdinArgs are appended and
+// input is read from stdin
+func (in Input) CmdInput(c *exec.Cmd, stdinArgs ...string) {
+	if in.Path != "" {
+		c.Args = append(c.Args, in.Path)
+		return
+	}
+	c.Args = append(c.Args, stdinArgs...)
+	c.Stdin = in.Reader
+}
+
+// Ext returns lower case file extension of name including dot
+func (in Input) Ext() string {
+	return strings.ToLower(filepath.Ext(in.Name))
 }
 
 type Render interface {
-	CanHandle(bs []byte) bool
-	Output(path string, rRes Resolution, rRange Range) (Output, error)
+	Name() string
+	// Probe returns confidence 0-100 that input can be rendered, 0 if not at all
+	Probe(in Input) int
+	Output(ctx context.Context, in Input, rRes Resolution, rRange Range, rOpts Options) (Output, error)
 }
 
 type Image interface {
@@ -27,7 +108,72 @@ type Image interface {
 	Image() image.Image
 }
 
+// Animation is implemented by images that also has an animated version,
+// Image() is the first frame
+type Animation interface {
+	// GIF returns animation as GIF
+	GIF() []byte
+}
+
+// Partial is implemented by images that will be followed by a more complete
+// version of the same image, ex: a tile strip while frames are decoded
+type Partial interface {
+	Partial() bool
+}
+
+// Warner is implemented by images that can have a warning about how they
+// were rendered, ex: frames that did not fit
+type Warner interface {
+	Warning() string
+}
+
 type Output interface {
 	String() string
-	Images() []Image
+	// Images are sent as soon as they are rendered, closed when done
+	Images() <-chan Image
+	// Err returns rendering error, only valid after Images is closed
+	Err() error
+}
+
+// Send returns a closed channel with already rendered images
+func Send(is ...Image) <-chan Image {
+	c := make(chan Image, len(is))
+	for _, i := range is {
+		c <- i
+	}
+	close(c)
+	return c
+}
+
+// Find renderer by name
+func Find(rs []Render, name string) (Render, error) {
+	for _, r := range rs {
+		if r.Name() == name {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown renderer %q, should be one of: %s", name, strings.Join(Names(rs), ", "))
+}
+
+// Detect returns renderer with highest confidence, first one wins if equal
+func Detect(rs []Render, in Input) (Render, error) {
+	var best Render
+	bestScore := 0
+	for _, r := range rs {
+		if s := r.Probe(in); s > bestScore {
+			best, bestScore = r, s
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no renderer for %s, force one of: %s", in.MIME, strings.Join(Names(rs), ", "))
+	}
+	return best, nil
+}
+
+func Names(rs []Render) []string {
+	var ns []string
+	for _, r := range rs {
+		ns = append(ns, r.Name())
+	}
+	return ns
 }
@@ -2,23 +2,36 @@ package rsvg
 
 import (
 	"bytes"
+	"context"
 	"image"
 	"os/exec"
-	"strings"
 
+	"github.com/wader/ffcat/internal/procgroup"
 	"github.com/wader/ffcat/internal/render"
+	"github.com/wader/ffcat/internal/sniff"
 )
 
 type Render struct{}
 
-func (Render) CanHandle(bs []byte) bool {
-	// TODO: improve
-	return strings.Contains(strings.ToLower(string(bs)), "<svg")
+func (Render) Name() string { return "rsvg" }
+
+func (Render) Probe(in render.Input) int {
+	if _, err := exec.LookPath("rsvg-convert"); err != nil {
+		return 0
+	}
+	switch {
+	case in.MIME == sniff.SVG:
+		return 80
+	case in.Ext() == ".svg":
+		return 40
+	}
+	return 0
 }
 
-func (Render) Output(path string, rRes render.Resolution, rRange render.Range) (render.Output, error) {
-	c := exec.Command("rsvg-convert", "-f", "png", path)
-	bs, err := c.Output()
+func (Render) Output(ctx context.Context, in render.Input, rRes render.Resolution, rRange render.Range, rOpts render.Options) (render.Output, error) {
+	c := exec.CommandContext(ctx, "rsvg-convert", "-f", "png")
+	in.CmdInput(c)
+	bs, err := procgroup.Output(ctx, c)
 	if err != nil {
 		return Output{}, err
 	}
@@ -35,8 +48,9 @@ type Output struct {
 	i image.Image
 }
 
-func (o Output) String() string         { return "svg" }
-func (o Output) Images() []render.Image { return []render.Image{Image{i: o.i}} }
+func (o Output) String() string              { return "svg" }
+func (o Output) Images() <-chan render.Image { return render.Send(Image{i: o.i}) }
+func (o Output) Err() error                  { return nil }
 
 type Image struct{ i image.Image }
 
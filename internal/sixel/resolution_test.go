@@ -0,0 +1,28 @@
+package sixel
+
+import (
+	"testing"
+
+	"github.com/wader/ffcat/internal/display"
+)
+
+func TestResolution(t *testing.T) {
+	testCases := []struct {
+		name       string
+		cellWidth  int
+		cellHeight int
+		expected   display.Resolution
+	}{
+		{name: "reported", cellWidth: 8, cellHeight: 16, expected: display.Resolution{Width: 640, Height: 384, WidthAlign: 8, HeightAlign: 16}},
+		{name: "unknown", cellWidth: 0, cellHeight: 0, expected: display.Resolution{Width: 800, Height: 480, WidthAlign: 10, HeightAlign: 20}},
+		{name: "zero height", cellWidth: 8, cellHeight: 0, expected: display.Resolution{Width: 800, Height: 480, WidthAlign: 10, HeightAlign: 20}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			actual := resolution(80, 24, tC.cellWidth, tC.cellHeight)
+			if tC.expected != actual {
+				t.Errorf("expected %+v, got %+v", tC.expected, actual)
+			}
+		})
+	}
+}
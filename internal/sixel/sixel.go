@@ -0,0 +1,282 @@
+package sixel
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/wader/ffcat/internal/display"
+	"github.com/wader/ffcat/internal/passthrough"
+	"github.com/wader/ffcat/internal/termquery"
+	"golang.org/x/term"
+)
+
+// max number of color registers most terminals support
+const maxColors = 256
+
+// transparent index used for pixels that should not be drawn
+const transparent = -1
+
+func Image(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	p := quantize(m, maxColors)
+	pm := image.NewPaletted(b, p)
+	draw.FloydSteinberg.Draw(pm, b, m, b.Min)
+
+	bw := bufio.NewWriter(w)
+	// P2=1 leaves pixels with no color set as transparent
+	bw.WriteString("\x1bP0;1;0q")
+	bw.WriteString("\"1;1;" + strconv.Itoa(b.Dx()) + ";" + strconv.Itoa(b.Dy()))
+
+	for i, c := range p {
+		r, g, b, _ := c.RGBA()
+		bw.WriteString("#" + strconv.Itoa(i) + ";2;" +
+			strconv.Itoa(int(r*100/0xffff)) + ";" +
+			strconv.Itoa(int(g*100/0xffff)) + ";" +
+			strconv.Itoa(int(b*100/0xffff)))
+	}
+
+	width := b.Dx()
+	indexes := make([]int, width*6)
+	used := make([]bool, len(p))
+	band := make([]byte, width)
+
+	for y := b.Min.Y; y < b.Max.Y; y += 6 {
+		for i := range used {
+			used[i] = false
+		}
+		for dy := 0; dy < 6; dy++ {
+			for x := 0; x < width; x++ {
+				ci := transparent
+				if y+dy < b.Max.Y {
+					if _, _, _, a := m.At(b.Min.X+x, y+dy).RGBA(); a >= 0x8000 {
+						ci = int(pm.ColorIndexAt(b.Min.X+x, y+dy))
+						used[ci] = true
+					}
+				}
+				indexes[dy*width+x] = ci
+			}
+		}
+
+		first := true
+		for ci, u := range used {
+			if !u {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				var v byte
+				for dy := 0; dy < 6; dy++ {
+					if indexes[dy*width+x] == ci {
+						v |= 1 << dy
+					}
+				}
+				band[x] = '?' + v
+			}
+			if !first {
+				// graphics carriage return, overlay next color on same band
+				bw.WriteByte('$')
+			}
+			first = false
+			bw.WriteString("#" + strconv.Itoa(ci))
+			writeRLE(bw, band)
+		}
+		// graphics new line, move to next band
+		bw.WriteByte('-')
+	}
+
+	bw.WriteString("\x1b\\")
+
+	return bw.Flush()
+}
+
+// writeRLE writes sixel characters using "!<count><char>" for repeats
+func writeRLE(w *bufio.Writer, bs []byte) {
+	// trailing empty sixels are not needed
+	end := len(bs)
+	for end > 0 && bs[end-1] == '?' {
+		end--
+	}
+	for i := 0; i < end; {
+		j := i + 1
+		for j < end && bs[j] == bs[i] {
+			j++
+		}
+		n := j - i
+		if n > 3 {
+			w.WriteString("!" + strconv.Itoa(n))
+			w.WriteByte(bs[i])
+		} else {
+			for k := 0; k < n; k++ {
+				w.WriteByte(bs[i])
+			}
+		}
+		i = j
+	}
+}
+
+type colorBox struct {
+	colors []color.RGBA
+}
+
+func (cb colorBox) channelRange() (int, uint8) {
+	var min, max [3]uint8
+	min = [3]uint8{255, 255, 255}
+	for _, c := range cb.colors {
+		for i, v := range [3]uint8{c.R, c.G, c.B} {
+			if v < min[i] {
+				min[i] = v
+			}
+			if v > max[i] {
+				max[i] = v
+			}
+		}
+	}
+	channel, r := 0, uint8(0)
+	for i := 0; i < 3; i++ {
+		if max[i]-min[i] >= r {
+			channel, r = i, max[i]-min[i]
+		}
+	}
+	return channel, r
+}
+
+func (cb colorBox) average() color.RGBA {
+	var r, g, b int
+	for _, c := range cb.colors {
+		r += int(c.R)
+		g += int(c.G)
+		b += int(c.B)
+	}
+	n := len(cb.colors)
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 0xff}
+}
+
+// quantize builds a palette of at most n colors using median cut
+func quantize(m image.Image, n int) color.Palette {
+	b := m.Bounds()
+	seen := map[color.RGBA]bool{}
+	var colors []color.RGBA
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)
+			if c.A < 0x80 {
+				continue
+			}
+			c.A = 0xff
+			if !seen[c] {
+				seen[c] = true
+				colors = append(colors, c)
+			}
+		}
+	}
+	if len(colors) == 0 {
+		return color.Palette{color.RGBA{A: 0xff}}
+	}
+
+	boxes := []colorBox{{colors: colors}}
+	for len(boxes) < n {
+		bi, bc, br := -1, 0, uint8(0)
+		for i, cb := range boxes {
+			if len(cb.colors) < 2 {
+				continue
+			}
+			if c, r := cb.channelRange(); bi == -1 || r > br {
+				bi, bc, br = i, c, r
+			}
+		}
+		if bi == -1 {
+			break
+		}
+
+		cs := boxes[bi].colors
+		sortByChannel(cs, bc)
+		mid := len(cs) / 2
+		boxes[bi] = colorBox{colors: cs[:mid]}
+		boxes = append(boxes, colorBox{colors: cs[mid:]})
+	}
+
+	p := make(color.Palette, len(boxes))
+	for i, cb := range boxes {
+		p[i] = cb.average()
+	}
+	return p
+}
+
+func sortByChannel(cs []color.RGBA, channel int) {
+	// counting sort, channel values are bytes
+	var buckets [256][]color.RGBA
+	for _, c := range cs {
+		v := [3]uint8{c.R, c.G, c.B}[channel]
+		buckets[v] = append(buckets[v], c)
+	}
+	i := 0
+	for _, b := range buckets {
+		i += copy(cs[i:], b)
+	}
+}
+
+// IsCompatible asks the terminal for its primary device attributes (DA1)
+// and checks if sixel graphics (attribute 4) is supported
+func IsCompatible(f *os.File) bool {
+	if !term.IsTerminal(int(f.Fd())) {
+		return false
+	}
+	as, err := termquery.New(f).DeviceAttributes()
+	if err != nil {
+		return false
+	}
+	for _, a := range as {
+		if a == 4 {
+			return true
+		}
+	}
+	return false
+}
+
+func PixelResolution(f *os.File) (display.Resolution, error) {
+	t := termquery.New(f)
+	w, h, err := t.Size()
+	if err != nil {
+		return display.Resolution{}, err
+	}
+	cellWidth, cellHeight, err := t.CellSize()
+	if err != nil {
+		cellWidth, cellHeight = 0, 0
+	}
+
+	return resolution(w, h, cellWidth, cellHeight), nil
+}
+
+// resolution returns pixel resolution for w times h cells
+func resolution(w, h, cellWidth, cellHeight int) display.Resolution {
+	// some terminals don't report cell size, assume a common one
+	if cellWidth <= 0 || cellHeight <= 0 {
+		cellWidth, cellHeight = 10, 20
+	}
+
+	return display.Resolution{
+		Width:       w * cellWidth,
+		Height:      h * cellHeight,
+		WidthAlign:  cellWidth,
+		HeightAlign: cellHeight,
+	}
+}
+
+type Display struct{}
+
+func (Display) Name() string                                           { return "sixel" }
+func (Display) Detect(f *os.File) bool                                 { return IsCompatible(f) }
+func (Display) PixelResolution(f *os.File) (display.Resolution, error) { return PixelResolution(f) }
+func (Display) Image(w io.Writer, m image.Image, r display.Resolution) error {
+	pw := passthrough.NewWriter(w, passthrough.Detect())
+	if err := Image(pw, m); err != nil {
+		return err
+	}
+	return pw.Flush()
+}
+func (Display) ClearScrollback(w io.Writer) error { return display.ClearScrollback(w) }
+func (Display) IsText() bool                      { return false }
@@ -0,0 +1,60 @@
+package sixel_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/wader/ffcat/internal/sixel"
+)
+
+func TestImage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		m        image.Image
+		expected string
+	}{
+		{
+			name: "solid",
+			m: func() image.Image {
+				m := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+				for y := 0; y < 6; y++ {
+					for x := 0; x < 8; x++ {
+						m.Set(x, y, color.NRGBA{R: 0xff, A: 0xff})
+					}
+				}
+				return m
+			}(),
+			expected: "\x1bP0;1;0q\"1;1;8;6#0;2;100;0;0#0!8~-\x1b\\",
+		},
+		{
+			name: "two colors",
+			m: func() image.Image {
+				m := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+				m.Set(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+				m.Set(1, 0, color.NRGBA{R: 0xff, A: 0xff})
+				m.Set(0, 1, color.NRGBA{B: 0xff, A: 0xff})
+				m.Set(1, 1, color.NRGBA{B: 0xff, A: 0xff})
+				return m
+			}(),
+			expected: "\x1bP0;1;0q\"1;1;2;2#0;2;100;0;0#1;2;0;0;100#0@@$#1AA-\x1b\\",
+		},
+		{
+			name:     "transparent",
+			m:        image.NewNRGBA(image.Rect(0, 0, 3, 1)),
+			expected: "\x1bP0;1;0q\"1;1;3;1#0;2;0;0;0-\x1b\\",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := sixel.Image(b, tC.m); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tC.expected {
+				t.Errorf("expected %q, got %q", tC.expected, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Package sniff detects content type of input using magic numbers.
+package sniff
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+const (
+	SVG         = "image/svg+xml"
+	Graphviz    = "text/vnd.graphviz"
+	OctetStream = "application/octet-stream"
+)
+
+type signature func(bs []byte) string
+
+// checked before http.DetectContentType which does not know about these
+var signatures = []signature{
+	svg,
+	graphviz,
+	prefix("fLaC", "audio/flac"),
+	prefix("YUV4MPEG2", "video/x-yuv4mpegpipe"),
+	mpegTS,
+	isoBMFF,
+}
+
+// checked if http.DetectContentType finds nothing, weak signatures that
+// could match text with byte order mark etc
+var fallbacks = []signature{
+	mpegAudio,
+}
+
+func prefix(p string, mime string) signature {
+	return func(bs []byte) string {
+		if bytes.HasPrefix(bs, []byte(p)) {
+			return mime
+		}
+		return ""
+	}
+}
+
+// ContentType returns MIME type without parameters, application/octet-stream
+// if unknown
+func ContentType(bs []byte) string {
+	for _, s := range signatures {
+		if t := s(bs); t != "" {
+			return t
+		}
+	}
+	t := http.DetectContentType(bs)
+	if i := strings.IndexByte(t, ';'); i != -1 {
+		t = t[:i]
+	}
+	if t == OctetStream {
+		for _, s := range fallbacks {
+			if t := s(bs); t != "" {
+				return t
+			}
+		}
+	}
+	return t
+}
+
+// skipPrefix skips bs up to and including end if it starts with start
+func skipPrefix(bs []byte, start string, end string) ([]byte, bool) {
+	if !bytes.HasPrefix(bs, []byte(start)) {
+		return bs, false
+	}
+	i := bytes.Index(bs[len(start):], []byte(end))
+	if i == -1 {
+		return nil, true
+	}
+	return bs[len(start)+i+len(end):], true
+}
+
+func trimSpace(bs []byte) []byte {
+	bs = bytes.TrimPrefix(bs, []byte("\xef\xbb\xbf"))
+	return bytes.TrimLeft(bs, " \t\r\n")
+}
+
+// svg skips xml declaration, comments and doctype and looks for a svg root
+// element
+func svg(bs []byte) string {
+	for {
+		bs = trimSpace(bs)
+		var ok bool
+		if bs, ok = skipPrefix(bs, "<?", "?>"); ok {
+			continue
+		}
+		if bs, ok = skipPrefix(bs, "<!--", "-->"); ok {
+			continue
+		}
+		if bytes.HasPrefix(bytes.ToLower(bs), []byte("<!doctype svg")) {
+			return SVG
+		}
+		if bs, ok = skipPrefix(bs, "<!", ">"); ok {
+			continue
+		}
+		break
+	}
+	if bytes.HasPrefix(bs, []byte("<svg")) && len(bs) > 4 && isSpaceOrByte(bs[4], '>') {
+		return SVG
+	}
+	return ""
+}
+
+// graphviz skips comments and looks for [strict] (di)graph keyword
+func graphviz(bs []byte) string {
+	for {
+		bs = trimSpace(bs)
+		var ok bool
+		if bs, ok = skipPrefix(bs, "//", "\n"); ok {
+			continue
+		}
+		if bs, ok = skipPrefix(bs, "#", "\n"); ok {
+			continue
+		}
+		if bs, ok = skipPrefix(bs, "/*", "*/"); ok {
+			continue
+		}
+		break
+	}
+	// keywords are case-insensitive
+	lbs := bytes.ToLower(bs)
+	if k := []byte("strict"); bytes.HasPrefix(lbs, k) && len(lbs) > len(k) && isSpaceOrByte(lbs[len(k)], 0) {
+		lbs = trimSpace(lbs[len(k):])
+	}
+	for _, k := range [][]byte{[]byte("digraph"), []byte("graph")} {
+		if bytes.HasPrefix(lbs, k) && len(lbs) > len(k) && isSpaceOrByte(lbs[len(k)], '{') {
+			return Graphviz
+		}
+	}
+	return ""
+}
+
+func isSpaceOrByte(b byte, o byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || (o != 0 && b == o)
+}
+
+// mpegTS checks sync byte of the first two 188 byte packets
+func mpegTS(bs []byte) string {
+	if len(bs) > 188 && bs[0] == 0x47 && bs[188] == 0x47 {
+		return "video/mp2t"
+	}
+	return ""
+}
+
+// isoBMFF looks at the major brand of the ftyp box, http.DetectContentType
+// only knows about mp4 brands
+func isoBMFF(bs []byte) string {
+	if len(bs) < 12 || string(bs[4:8]) != "ftyp" {
+		return ""
+	}
+	switch string(bs[8:12]) {
+	case "qt  ":
+		return "video/quicktime"
+	case "avif", "avis":
+		return "image/avif"
+	case "heic", "heix", "mif1", "msf1":
+		return "image/heic"
+	case "M4A ", "M4B ":
+		return "audio/mp4"
+	}
+	return "video/mp4"
+}
+
+// mpegAudio checks for a MPEG audio or ADTS AAC frame sync without ID3 tag
+func mpegAudio(bs []byte) string {
+	if len(bs) < 3 || bs[0] != 0xff || bs[1]&0xe0 != 0xe0 {
+		return ""
+	}
+	// layer bits 0 is ADTS which has a 12 bit sync
+	if bs[1]&0x06 == 0 {
+		if bs[1]&0xf0 == 0xf0 {
+			return "audio/aac"
+		}
+		return ""
+	}
+	// bad bitrate or reserved sample rate
+	if bs[2]>>4 == 0xf || (bs[2]>>2)&0x3 == 0x3 {
+		return ""
+	}
+	return "audio/mpeg"
+}
@@ -0,0 +1,44 @@
+package sniff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wader/ffcat/internal/sniff"
+)
+
+func TestContentType(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: `<svg xmlns="http://www.w3.org/2000/svg"></svg>`, expected: sniff.SVG},
+		{input: "\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- c -->\n<!DOCTYPE svg PUBLIC \"-//W3C//DTD SVG 1.1//EN\">\n<svg>", expected: sniff.SVG},
+		{input: "<?xml version=\"1.0\"?>\n<svg\n width=\"1\">", expected: sniff.SVG},
+		{input: "<?xml version=\"1.0\"?>\n<html><svg>", expected: "text/xml"},
+		{input: "package main\n// draws <svg> things", expected: "text/plain"},
+		{input: "digraph {a -> b}", expected: sniff.Graphviz},
+		{input: "// comment\n/* more */\n# line\nstrict DiGraph G {}", expected: sniff.Graphviz},
+		{input: "graph{}", expected: sniff.Graphviz},
+		{input: "graphics are nice", expected: "text/plain"},
+		{input: "the digraph is", expected: "text/plain"},
+		{input: "fLaC\x00\x00\x00\x22", expected: "audio/flac"},
+		{input: "\x47" + strings.Repeat("\x00", 187) + "\x47", expected: "video/mp2t"},
+		{input: "\x00\x00\x00\x18ftypisom\x00\x00\x02\x00", expected: "video/mp4"},
+		{input: "\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00", expected: "video/quicktime"},
+		{input: "\x00\x00\x00\x1cftypavif\x00\x00\x00\x00", expected: "image/avif"},
+		{input: "\xff\xfb\x90\x00", expected: "audio/mpeg"},
+		{input: "\xff\xf1\x50\x80\x02\x1f\xfc", expected: "audio/aac"},
+		{input: "\xff\xfea\x00b\x00", expected: "text/plain"},
+		{input: "\x89PNG\r\n\x1a\n", expected: "image/png"},
+		{input: "\x00\x01\x02\x03", expected: sniff.OctetStream},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.input, func(t *testing.T) {
+			actual := sniff.ContentType([]byte(tC.input))
+			if tC.expected != actual {
+				t.Errorf("expected %q, got %q", tC.expected, actual)
+			}
+		})
+	}
+}
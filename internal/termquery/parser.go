@@ -0,0 +1,157 @@
+package termquery
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	CSI Kind = iota + 1
+	OSC
+	DCS
+	APC
+)
+
+func (k Kind) String() string {
+	switch k {
+	case CSI:
+		return "CSI"
+	case OSC:
+		return "OSC"
+	case DCS:
+		return "DCS"
+	case APC:
+		return "APC"
+	}
+	return "unknown"
+}
+
+// Reply is a control sequence sent by the terminal
+type Reply struct {
+	Kind Kind
+	// CSI parameter and intermediate bytes, string for OSC, DCS and APC
+	Data string
+	// CSI final byte
+	Final byte
+}
+
+// Params returns CSI parameters, private marker like "?" is skipped
+func (r Reply) Params() []int {
+	s := strings.TrimLeft(r.Data, "?<=>")
+	if s == "" {
+		return nil
+	}
+	var ps []int
+	for _, p := range strings.Split(s, ";") {
+		n, _ := strconv.Atoi(p)
+		ps = append(ps, n)
+	}
+	return ps
+}
+
+func (r Reply) String() string {
+	if r.Kind == CSI {
+		return r.Kind.String() + " " + strconv.Quote(r.Data+string(r.Final))
+	}
+	return r.Kind.String() + " " + strconv.Quote(r.Data)
+}
+
+type state int
+
+const (
+	stateGround state = iota
+	stateEscape
+	stateSS3
+	stateCSI
+	stateString
+	stateStringEscape
+)
+
+const (
+	bel = 0x07
+	esc = 0x1b
+)
+
+// parser is a state machine that finds control sequence replies in input.
+// Anything else, like user key presses, is skipped.
+type parser struct {
+	state state
+	kind  Kind
+	buf   []byte
+}
+
+func (p *parser) reset(s state) {
+	p.state = s
+	p.buf = p.buf[:0]
+}
+
+func (p *parser) emit(final byte) Reply {
+	r := Reply{Kind: p.kind, Data: string(p.buf), Final: final}
+	p.reset(stateGround)
+	return r
+}
+
+// feed one byte, returns reply if b completed one
+func (p *parser) feed(b byte) (Reply, bool) {
+	switch p.state {
+	case stateGround:
+		if b == esc {
+			p.reset(stateEscape)
+		}
+	case stateEscape:
+		switch b {
+		case '[':
+			p.kind = CSI
+			p.reset(stateCSI)
+		case ']':
+			p.kind = OSC
+			p.reset(stateString)
+		case 'P':
+			p.kind = DCS
+			p.reset(stateString)
+		case '_':
+			p.kind = APC
+			p.reset(stateString)
+		case 'O':
+			// SS3, used by some keys, skip next byte
+			p.reset(stateSS3)
+		case esc:
+			p.reset(stateEscape)
+		default:
+			p.reset(stateGround)
+		}
+	case stateSS3:
+		p.reset(stateGround)
+	case stateCSI:
+		switch {
+		case b >= 0x20 && b <= 0x3f:
+			p.buf = append(p.buf, b)
+		case b >= 0x40 && b <= 0x7e:
+			return p.emit(b), true
+		case b == esc:
+			p.reset(stateEscape)
+		default:
+			p.reset(stateGround)
+		}
+	case stateString:
+		switch b {
+		case bel:
+			return p.emit(0), true
+		case esc:
+			p.state = stateStringEscape
+		default:
+			p.buf = append(p.buf, b)
+		}
+	case stateStringEscape:
+		if b == '\\' {
+			return p.emit(0), true
+		}
+		// not a string terminator, string was aborted by a new sequence
+		p.reset(stateEscape)
+		return p.feed(b)
+	}
+
+	return Reply{}, false
+}
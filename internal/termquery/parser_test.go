@@ -0,0 +1,54 @@
+package termquery
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []Reply
+	}{
+		{input: "", expected: nil},
+		{input: "abc", expected: nil},
+		{input: "\x1b[?62;4;22c", expected: []Reply{{Kind: CSI, Data: "?62;4;22", Final: 'c'}}},
+		{input: "\x1b[6;20;10t", expected: []Reply{{Kind: CSI, Data: "6;20;10", Final: 't'}}},
+		{
+			input:    "\x1b]1337;ReportCellSize=14.0;6.0;1.0\x1b\\",
+			expected: []Reply{{Kind: OSC, Data: "1337;ReportCellSize=14.0;6.0;1.0"}},
+		},
+		{input: "\x1b]11;rgb:0000/0000/0000\x07", expected: []Reply{{Kind: OSC, Data: "11;rgb:0000/0000/0000"}}},
+		{input: "\x1bP1$r0m\x1b\\", expected: []Reply{{Kind: DCS, Data: "1$r0m"}}},
+		{input: "\x1b_Gi=31;OK\x1b\\", expected: []Reply{{Kind: APC, Data: "Gi=31;OK"}}},
+		// interleaved user input
+		{
+			input: "a\x1b[Ab\x1bOPc\x1b[?62c",
+			expected: []Reply{
+				{Kind: CSI, Data: "", Final: 'A'},
+				{Kind: CSI, Data: "?62", Final: 'c'},
+			},
+		},
+		// aborted string
+		{
+			input:    "\x1b]11;abc\x1b[?1c",
+			expected: []Reply{{Kind: CSI, Data: "?1", Final: 'c'}},
+		},
+	}
+	for i, tC := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var p parser
+			var actual []Reply
+			// feed byte by byte to make sure split replies work
+			for _, b := range []byte(tC.input) {
+				if r, ok := p.feed(b); ok {
+					actual = append(actual, r)
+				}
+			}
+			if !reflect.DeepEqual(tC.expected, actual) {
+				t.Errorf("expected %v, got %v", tC.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Package termquery sends queries to a terminal and reads replies.
+package termquery
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/wader/ffcat/internal/passthrough"
+	"golang.org/x/term"
+)
+
+// ErrTimeout no reply within timeout
+var ErrTimeout = errors.New("terminal query timeout")
+
+// ErrNotSupported terminal replied to device attributes but not to query
+var ErrNotSupported = errors.New("terminal query not supported")
+
+// DefaultTimeout is reply timeout for new terminals
+var DefaultTimeout = time.Second
+
+// primary device attributes, answered by almost all terminals
+const da1 = "\x1b[c"
+
+func isDA1(r Reply) bool {
+	return r.Kind == CSI && r.Final == 'c' && len(r.Data) > 0 && r.Data[0] == '?'
+}
+
+type Terminal struct {
+	f       *os.File
+	Timeout time.Duration
+	// Queries are wrapped to reach the outer terminal if inside a multiplexer
+	Multiplexer passthrough.Multiplexer
+}
+
+func New(f *os.File) *Terminal {
+	return &Terminal{
+		f:           f,
+		Timeout:     DefaultTimeout,
+		Multiplexer: passthrough.Detect(),
+	}
+}
+
+// Query writes request and reads replies until match returns true.
+// Device attributes is requested after request and its reply is used to know
+// that terminal will not reply so that there is no need to wait for timeout.
+// Replies not matching and other input are skipped.
+func (t *Terminal) Query(request string, match func(r Reply) bool) (Reply, error) {
+	fd := int(t.f.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer restore()
+
+	if request != da1 {
+		request += da1
+	}
+	request = t.Multiplexer.WrapString(request)
+	if _, err := t.f.Write([]byte(request)); err != nil {
+		return Reply{}, err
+	}
+
+	var p parser
+	var found Reply
+	var foundOk bool
+	deadline := time.Now().Add(t.Timeout)
+	// only works if file is non-blocking, otherwise raw mode read timeout is used
+	_ = t.f.SetReadDeadline(deadline)
+	defer t.f.SetReadDeadline(time.Time{})
+	b := make([]byte, 256)
+	for {
+		// read returns zero bytes (io.EOF) on raw mode read timeout
+		n, err := t.f.Read(b)
+		if err == io.EOF || errors.Is(err, os.ErrDeadlineExceeded) {
+			n = 0
+		} else if err != nil {
+			return Reply{}, err
+		}
+		for _, c := range b[0:n] {
+			r, ok := p.feed(c)
+			if !ok {
+				continue
+			}
+			if !foundOk && match(r) {
+				found, foundOk = r, true
+			}
+			if isDA1(r) {
+				if foundOk {
+					return found, nil
+				}
+				return Reply{}, ErrNotSupported
+			}
+		}
+		if time.Now().After(deadline) {
+			if foundOk {
+				return found, nil
+			}
+			return Reply{}, ErrTimeout
+		}
+	}
+}
+
+// DeviceAttributes returns primary device attributes (DA1)
+func (t *Terminal) DeviceAttributes() ([]int, error) {
+	r, err := t.Query(da1, isDA1)
+	if err != nil {
+		return nil, err
+	}
+	return r.Params(), nil
+}
+
+// Size returns size in cells
+func (t *Terminal) Size() (int, int, error) {
+	return term.GetSize(int(t.f.Fd()))
+}
+
+// CellSize returns size of a cell in pixels. Tries tty window size pixel
+// fields then XTWINOPS cell size and text area size.
+func (t *Terminal) CellSize() (int, int, error) {
+	cols, rows, err := t.Size()
+	if err != nil {
+		return 0, 0, err
+	}
+	if cols == 0 || rows == 0 {
+		return 0, 0, errors.New("terminal has no size")
+	}
+
+	if pw, ph, err := pixelSize(int(t.f.Fd())); err == nil && pw > 0 && ph > 0 {
+		return pw / cols, ph / rows, nil
+	}
+
+	isWindowOp := func(n int) func(r Reply) bool {
+		return func(r Reply) bool {
+			ps := r.Params()
+			return r.Kind == CSI && r.Final == 't' && len(ps) == 3 && ps[0] == n
+		}
+	}
+
+	// reply is CSI 6 ; height ; width t
+	if r, err := t.Query("\x1b[16t", isWindowOp(6)); err == nil {
+		ps := r.Params()
+		if ps[1] > 0 && ps[2] > 0 {
+			return ps[2], ps[1], nil
+		}
+	}
+	// reply is CSI 4 ; height ; width t
+	r, err := t.Query("\x1b[14t", isWindowOp(4))
+	if err != nil {
+		return 0, 0, err
+	}
+	ps := r.Params()
+	if ps[1] == 0 || ps[2] == 0 {
+		return 0, 0, ErrNotSupported
+	}
+	return ps[2] / cols, ps[1] / rows, nil
+}
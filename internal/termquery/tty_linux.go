@@ -0,0 +1,9 @@
+//go:build aix || linux || solaris
+// +build aix linux solaris
+
+package termquery
+
+import "golang.org/x/sys/unix"
+
+const ioctlReadTermios = unix.TCGETS
+const ioctlWriteTermios = unix.TCSETS
@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package termquery
+
+import "errors"
+
+var errNotSupported = errors.New("terminal queries not supported on this platform")
+
+func makeRaw(fd int) (func() error, error) { return nil, errNotSupported }
+
+func pixelSize(fd int) (int, int, error) { return 0, 0, errNotSupported }
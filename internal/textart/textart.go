@@ -0,0 +1,225 @@
+// Package textart renders images as monochrome text using braille patterns or
+// an ASCII luminance ramp.
+package textart
+
+import (
+	"bufio"
+	"image"
+	"io"
+	"os"
+
+	"github.com/wader/ffcat/internal/display"
+)
+
+type Mode int
+
+const (
+	Braille Mode = iota
+	ASCII
+)
+
+type Dither int
+
+const (
+	FloydSteinberg Dither = iota
+	Ordered
+	NoDither
+)
+
+// Size of a cell in virtual pixels, see display.TextCellWidth
+const (
+	CellWidth  = display.TextCellWidth
+	CellHeight = display.TextCellHeight
+)
+
+// dots per cell for braille, one "dot" per cell for ascii
+const (
+	brailleDotsX = 2
+	brailleDotsY = 4
+)
+
+// from dark to bright
+const asciiRamp = " .:-=+*#%@"
+
+// 4x4 bayer matrix for ordered dithering
+var bayer4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+func PixelResolution(f *os.File) (display.Resolution, error) {
+	return display.TextPixelResolution(f)
+}
+
+// luminance returns a grid of average luminance 0-1 for blocks of bw x bh
+// pixels, transparent counts as dark
+func luminance(m image.Image, bw int, bh int) [][]float64 {
+	b := m.Bounds()
+	w := (b.Dx() + bw - 1) / bw
+	h := (b.Dy() + bh - 1) / bh
+	g := make([][]float64, h)
+	for gy := range g {
+		g[gy] = make([]float64, w)
+		for gx := range g[gy] {
+			r := image.Rect(
+				b.Min.X+gx*bw, b.Min.Y+gy*bh,
+				b.Min.X+(gx+1)*bw, b.Min.Y+(gy+1)*bh,
+			).Intersect(b)
+			var sum float64
+			n := 0
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					// premultiplied so transparent is black
+					cr, cg, cb, _ := m.At(x, y).RGBA()
+					sum += (0.2126*float64(cr) + 0.7152*float64(cg) + 0.0722*float64(cb)) / 0xffff
+					n++
+				}
+			}
+			if n > 0 {
+				g[gy][gx] = sum / float64(n)
+			}
+		}
+	}
+	return g
+}
+
+// quantize grid values in-place to levels-1 steps (0, 1/(levels-1), ... 1)
+func quantize(g [][]float64, levels int, d Dither) {
+	steps := float64(levels - 1)
+	for y := range g {
+		for x := range g[y] {
+			v := g[y][x]
+			if d == Ordered {
+				// shift threshold by matrix value in range -0.5..0.5 of a step
+				v += (bayer4[y%4][x%4]/16 - 0.5) / steps
+			}
+			q := float64(int(v*steps+0.5)) / steps
+			if q < 0 {
+				q = 0
+			} else if q > 1 {
+				q = 1
+			}
+			g[y][x] = q
+
+			if d != FloydSteinberg {
+				continue
+			}
+			e := v - q
+			spread := func(dx, dy int, f float64) {
+				if y+dy < len(g) && x+dx >= 0 && x+dx < len(g[y+dy]) {
+					g[y+dy][x+dx] += e * f
+				}
+			}
+			spread(1, 0, 7.0/16)
+			spread(-1, 1, 3.0/16)
+			spread(0, 1, 5.0/16)
+			spread(1, 1, 1.0/16)
+		}
+	}
+}
+
+// Image writes m as text. Image is downscaled to fit columns cells wide,
+// zero columns means no limit.
+func Image(w io.Writer, m image.Image, mode Mode, dither Dither, columns int) error {
+	b := m.Bounds()
+
+	dotsX, dotsY, levels := 1, 1, len(asciiRamp)
+	if mode == Braille {
+		dotsX, dotsY, levels = brailleDotsX, brailleDotsY, 2
+	}
+
+	cw, ch := CellWidth, CellHeight
+	if columns > 0 && (b.Dx()+cw-1)/cw > columns {
+		cw = (b.Dx() + columns - 1) / columns
+		ch = cw * CellHeight / CellWidth
+	}
+	bw, bh := cw/dotsX, ch/dotsY
+	if bw < 1 {
+		bw = 1
+	}
+	if bh < 1 {
+		bh = 1
+	}
+
+	g := luminance(m, bw, bh)
+	quantize(g, levels, dither)
+
+	at := func(x, y int) float64 {
+		if y < len(g) && x < len(g[y]) {
+			return g[y][x]
+		}
+		return 0
+	}
+
+	rows := (len(g) + dotsY - 1) / dotsY
+	cols := 0
+	if len(g) > 0 {
+		cols = (len(g[0]) + dotsX - 1) / dotsX
+	}
+
+	bwr := bufio.NewWriter(w)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			if mode == Braille {
+				bwr.WriteRune(brailleRune(func(dx, dy int) bool {
+					return at(col*dotsX+dx, row*dotsY+dy) >= 0.5
+				}))
+			} else {
+				v := at(col, row)
+				bwr.WriteByte(asciiRamp[int(v*float64(len(asciiRamp)-1)+0.5)])
+			}
+		}
+		if row < rows-1 {
+			bwr.WriteString("\n")
+		}
+	}
+
+	return bwr.Flush()
+}
+
+// brailleRune returns the braille pattern with dots set
+// dot numbering and bits:
+// 1 4    0x01 0x08
+// 2 5    0x02 0x10
+// 3 6    0x04 0x20
+// 7 8    0x40 0x80
+func brailleRune(isSet func(dx, dy int) bool) rune {
+	bits := [brailleDotsY][brailleDotsX]rune{
+		{0x01, 0x08},
+		{0x02, 0x10},
+		{0x04, 0x20},
+		{0x40, 0x80},
+	}
+	r := rune(0x2800)
+	for dy := 0; dy < brailleDotsY; dy++ {
+		for dx := 0; dx < brailleDotsX; dx++ {
+			if isSet(dx, dy) {
+				r |= bits[dy][dx]
+			}
+		}
+	}
+	return r
+}
+
+type Display struct {
+	Mode   Mode
+	Dither Dither
+}
+
+func (d Display) Name() string {
+	if d.Mode == ASCII {
+		return "ascii"
+	}
+	return "braille"
+}
+
+// Detect is always false, only used if forced as ansi is preferred
+func (Display) Detect(f *os.File) bool                                 { return false }
+func (Display) PixelResolution(f *os.File) (display.Resolution, error) { return PixelResolution(f) }
+func (d Display) Image(w io.Writer, m image.Image, r display.Resolution) error {
+	return Image(w, m, d.Mode, d.Dither, r.Width/CellWidth)
+}
+func (Display) ClearScrollback(w io.Writer) error { return display.ClearScrollback(w) }
+func (Display) IsText() bool                      { return true }
@@ -0,0 +1,77 @@
+package textart_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/wader/ffcat/internal/textart"
+)
+
+// grays returns image with each column block of bw pixels filled with a gray
+// level, values 0-255
+func grays(bw int, h int, ys ...uint8) image.Image {
+	m := image.NewNRGBA(image.Rect(0, 0, bw*len(ys), h))
+	for i, y := range ys {
+		for py := 0; py < h; py++ {
+			for px := 0; px < bw; px++ {
+				m.Set(i*bw+px, py, color.Gray{Y: y})
+			}
+		}
+	}
+	return m
+}
+
+// dot returns one cell image with braille dot at dx, dy lit
+func dot(dx int, dy int) image.Image {
+	m := image.NewNRGBA(image.Rect(0, 0, textart.CellWidth, textart.CellHeight))
+	for y := 0; y < textart.CellHeight; y++ {
+		for x := 0; x < textart.CellWidth; x++ {
+			c := color.NRGBA{A: 0xff}
+			if x/(textart.CellWidth/2) == dx && y/(textart.CellHeight/4) == dy {
+				c = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			m.Set(x, y, c)
+		}
+	}
+	return m
+}
+
+func TestImage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		m        image.Image
+		mode     textart.Mode
+		dither   textart.Dither
+		columns  int
+		expected string
+	}{
+		{name: "braille dot 1", m: dot(0, 0), mode: textart.Braille, dither: textart.NoDither, expected: "⠁"},
+		{name: "braille dot 4", m: dot(1, 0), mode: textart.Braille, dither: textart.NoDither, expected: "⠈"},
+		{name: "braille dot 3", m: dot(0, 2), mode: textart.Braille, dither: textart.NoDither, expected: "⠄"},
+		{name: "braille dot 7", m: dot(0, 3), mode: textart.Braille, dither: textart.NoDither, expected: "⡀"},
+		{name: "braille dot 8", m: dot(1, 3), mode: textart.Braille, dither: textart.NoDither, expected: "⢀"},
+		{name: "braille all", m: grays(textart.CellWidth, textart.CellHeight, 255), mode: textart.Braille, dither: textart.NoDither, expected: "⣿"},
+		{name: "braille none", m: grays(textart.CellWidth, textart.CellHeight, 0), mode: textart.Braille, dither: textart.NoDither, expected: "⠀"},
+		{name: "ascii ramp ends", m: grays(textart.CellWidth, textart.CellHeight, 0, 255), mode: textart.ASCII, dither: textart.NoDither, expected: " @"},
+		{name: "ascii rows", m: grays(textart.CellWidth, textart.CellHeight*2, 255), mode: textart.ASCII, dither: textart.NoDither, expected: "@\n@"},
+		// four dots in a row, about 0.40, 0.45, 0.50 and 0.55
+		{name: "gradient no dither", m: grays(textart.CellWidth/2, textart.CellHeight/4, 102, 115, 128, 140), mode: textart.Braille, dither: textart.NoDither, expected: "⠀⠉"},
+		{name: "gradient ordered", m: grays(textart.CellWidth/2, textart.CellHeight/4, 102, 115, 128, 140), mode: textart.Braille, dither: textart.Ordered, expected: "⠀⠈"},
+		{name: "gradient floyd steinberg", m: grays(textart.CellWidth/2, textart.CellHeight/4, 102, 115, 128, 140), mode: textart.Braille, dither: textart.FloydSteinberg, expected: "⠈⠈"},
+		{name: "no columns limit", m: grays(textart.CellWidth*4, textart.CellHeight, 255), mode: textart.ASCII, dither: textart.NoDither, expected: "@@@@"},
+		{name: "columns downscale", m: grays(textart.CellWidth*4, textart.CellHeight*2, 255), mode: textart.ASCII, dither: textart.NoDither, columns: 2, expected: "@@"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			if err := textart.Image(b, tC.m, tC.mode, tC.dither, tC.columns); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tC.expected {
+				t.Errorf("expected %q, got %q", tC.expected, b.String())
+			}
+		})
+	}
+}
@@ -1,96 +1,148 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
 	"io"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
+	"syscall"
 
-	"github.com/wader/ffcat/internal/iterm2"
+	"github.com/wader/ffcat/internal/display"
+	displayall "github.com/wader/ffcat/internal/display/all"
+	"github.com/wader/ffcat/internal/goffmpeg"
+	"github.com/wader/ffcat/internal/imagefile"
+	"github.com/wader/ffcat/internal/procgroup"
 	"github.com/wader/ffcat/internal/render"
 	"github.com/wader/ffcat/internal/render/all"
+	"github.com/wader/ffcat/internal/render/ffmpeg"
+	"github.com/wader/ffcat/internal/sniff"
+	"github.com/wader/ffcat/internal/textart"
+	"golang.org/x/term"
 
 	_ "image/png"
 )
 
-type cut struct {
-	offset   float64
-	duration float64
-	delta    float64
+type rangeValue struct {
+	s string
+	r render.Range
 }
 
-func (c *cut) String() string {
-	return fmt.Sprintf("%f,%f,%f", c.offset, c.delta, c.duration)
-}
+func (r *rangeValue) String() string { return r.s }
 
-// TODO:
-// 123
-// 1:2
-// 1:2:3
-// 1:2:3.1
-// -10ms,0.1
-func (c *cut) Set(s string) error {
-	timeDeltaParts := strings.Split(s, ",")
-	timeParts := strings.Split(timeDeltaParts[0], ":")
-	if len(timeParts) == 1 {
-		c.offset, _ = strconv.ParseFloat(timeParts[0], 64)
-	} else {
-		h, m, s := float64(0), float64(0), float64(0)
-		s, _ = strconv.ParseFloat(timeParts[len(timeParts)-1], 64)
-		if len(timeParts) > 1 {
-			m, _ = strconv.ParseFloat(timeParts[len(timeParts)-2], 64)
-		}
-		if len(timeParts) > 2 {
-			h, _ = strconv.ParseFloat(timeParts[len(timeParts)-3], 64)
-		}
-		c.offset = (h * 60 * 60) + (m * 60) + s
-	}
-	if len(timeDeltaParts) > 1 {
-		c.delta, _ = strconv.ParseFloat(timeDeltaParts[1], 64)
-	}
-	if len(timeDeltaParts) > 2 {
-		c.duration, _ = strconv.ParseFloat(timeDeltaParts[2], 64)
+func (r *rangeValue) Set(s string) error {
+	rr, err := render.ParseRange(s)
+	if err != nil {
+		return err
 	}
-
+	r.s = s
+	r.r = rr
 	return nil
 }
 
-var rangeFlag = cut{
-	offset:   0,
-	duration: 5,
-	delta:    1,
+var rangeFlag = rangeValue{s: "0,1,5", r: render.DefaultRange}
+
+type gridValue struct {
+	columns int
+	rows    int
+}
+
+func (g *gridValue) String() string { return fmt.Sprintf("%dx%d", g.columns, g.rows) }
+
+func (g *gridValue) Set(s string) error {
+	var c, r int
+	if _, err := fmt.Sscanf(s, "%dx%d", &c, &r); err != nil || c < 0 || r < 0 {
+		return fmt.Errorf("invalid grid %q, should be COLUMNSxROWS", s)
+	}
+	g.columns, g.rows = c, r
+	return nil
 }
 
+var gridFlag gridValue
+
 var debugFlag = flag.Bool("d", false, "Debug")
 var verboseFlag = flag.Bool("v", false, "Verbose")
 var clearFlag = flag.Bool("c", false, "Clear")
+var outputFlag string
+var renderFlag string
+var listRenderersFlag = flag.Bool("list-renderers", false, "List renderers and exit")
+var animateFlag = flag.Bool("a", false, "Animate video")
+var interactiveFlag = flag.Bool("i", false, "Interactive, step thru video using keys")
+var writeFlag = flag.String("w", "", "Write image file instead of output to terminal (.png, .jpg), - for stdout as png")
+var sizeFlag = flag.String("size", "1280x720", "Resolution to use when writing image file")
+var ditherFlag = flag.String("dither", "fs", "Dither for braille and ascii output (fs, ordered, none)")
+var streamsFlag = flag.String("s", "", "Streams, comma separated ffmpeg stream specifiers (ex: v:0,a, m:language:eng)")
+var framesFlag = flag.String("frames", "", "Video frames to show instead of one per delta, comma separated, numbers count from start of range (ex: 0,10-20, /5, key, i, p, b, scene:0.3)")
+var maxTileWidthFlag = flag.Int("max-tile-width", 0, "Max video tile width in pixels, 0 for no limit")
+var audioFlag = flag.String("audio", render.AudioWave, "Audio as waveform, spectrogram, both or stereo vectorscope and phase meter (wave, spectrum, both, phase)")
+var audioCombinedFlag = flag.Bool("audio-combined", false, "Audio channels combined in one row")
+var spectrumScaleFlag = flag.String("spectrum-scale", "lin", "Spectrogram frequency scale (lin, log)")
+var spectrumColorFlag = flag.String("spectrum-color", "intensity", "Spectrogram color map (ex: intensity, rainbow, magma, viridis)")
+var spectrumWinFuncFlag = flag.String("spectrum-win-func", "hann", "Spectrogram window function (ex: hann, hamming, blackman)")
+var spectrumLegendFlag = flag.Bool("spectrum-legend", false, "Spectrogram frequency and time axes")
+var loudnessFlag = flag.Bool("loudness", false, "Audio EBU R128 loudness graph, summary in verbose output")
+var loudnessTargetFlag = flag.Int("loudness-target", -23, "Loudness graph target level in LUFS (-23 to 0)")
+var scopesFlag = flag.String("scopes", "", "Video scopes below each tile, comma separated (waveform, histogram, vectorscope)")
+var frameInfoFlag = flag.Bool("frame-info", false, "Annotate video tiles with picture type, key frame, size, pts and dts, border color by type (I red, P green, B blue)")
+var timeoutFlag = flag.Duration("timeout", 0, "Timeout per file, 0 for no timeout (ex: 10s)")
+
+// stderr if stdout is used for image file output
+var logWriter io.Writer = os.Stdout
 
 func verbosef(s string, args ...interface{}) {
 	if *verboseFlag {
-		fmt.Printf(s, args...)
+		fmt.Fprintf(logWriter, s, args...)
 	}
 }
 
 func debugf(s string, args ...interface{}) {
 	if *debugFlag {
-		fmt.Printf(s, args...)
+		fmt.Fprintf(logWriter, s, args...)
 	}
 }
 
 func init() {
-	flag.Var(&rangeFlag, "r", "Range [[hh:]mm:]ss[,delta[,duration]]")
+	flag.Var(&gridFlag, "grid", "Video tile grid COLUMNSxROWS, 0 to fit terminal (ex: 4x3, 6x0)")
+	flag.Var(&rangeFlag, "r", "Range start[-end][,delta[,duration]] (ex: 1:30, -10s, #120, 50%, 1m-1m30s,0.5)")
+	outputUsage := "Output (auto, " + strings.Join(display.Names(displayall.Displays), ", ") + ")"
+	flag.StringVar(&outputFlag, "o", "auto", outputUsage)
+	flag.StringVar(&outputFlag, "output", "auto", outputUsage)
+	flag.StringVar(&renderFlag, "f", "auto", "Renderer (auto, "+strings.Join(render.Names(all.Renderers), ", ")+")")
 }
 
-func previewFile(termRes iterm2.Resolution, path string, clear bool) error {
+func previewFile(ctx context.Context, forceRender render.Render, termRes display.Resolution, path string, imageFn func(im render.Image) error) error {
+	// also stops renderer if image output fails
+	var cancel context.CancelFunc
+	if *timeoutFlag > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeoutFlag)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
 
-	f, err := os.Open(path)
-	if err != nil {
-		return err
+	var in render.Input
+	if path == "-" {
+		in = render.Input{Name: "stdin", Reader: os.Stdin}
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = render.Input{Name: path, Reader: f}
+		// pipes like <(cmd) are read as stream
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			in.Path = path
+		}
 	}
+
 	probeBs := make([]byte, 512)
-	if n, err := io.ReadFull(f, probeBs[:]); err != nil {
+	if n, err := io.ReadFull(in.Reader, probeBs[:]); err != nil {
 		if err == io.ErrUnexpectedEOF {
 			probeBs = probeBs[0:n]
 		} else {
@@ -98,70 +150,288 @@ func previewFile(termRes iterm2.Resolution, path string, clear bool) error {
 		}
 	}
 
-	var r render.Render
-	for _, r = range all.Renderers {
-		if r.CanHandle(probeBs) {
-			break
+	in.Probe = probeBs
+	if f, ok := in.Reader.(*os.File); ok && in.Path != "" {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
 		}
+	} else {
+		in.Reader = io.MultiReader(bytes.NewReader(probeBs), in.Reader)
 	}
+
+	in.MIME = sniff.ContentType(probeBs)
+
+	r := forceRender
 	if r == nil {
-		return fmt.Errorf("failed to probe format")
-	}
-
-	o, err := r.Output(path, render.Resolution{
-		Width:       termRes.Width,
-		Height:      termRes.Height,
-		WidthAlign:  termRes.WidthAlign,
-		HeightAlign: termRes.HeightAlign,
-	}, render.Range{
-		Offset:   rangeFlag.offset,
-		Duration: rangeFlag.duration,
-		Delta:    rangeFlag.delta,
+		var err error
+		r, err = render.Detect(all.Renderers, in)
+		if err != nil {
+			return fmt.Errorf("%s: %w", in.Name, err)
+		}
+	}
+	debugf("%s: %s using %s\n", in.Name, in.MIME, r.Name())
+
+	o, err := r.Output(ctx, in, render.Resolution(termRes), rangeFlag.r, render.Options{
+		Animate: *animateFlag,
+		Streams: *streamsFlag,
+		Frames:  *framesFlag,
+		Columns: gridFlag.columns,
+		Rows:    gridFlag.rows,
+
+		MaxTileWidth:  *maxTileWidthFlag,
+		Audio:         *audioFlag,
+		AudioCombined: *audioCombinedFlag,
+		Spectrum: render.Spectrum{
+			Scale:   *spectrumScaleFlag,
+			Color:   *spectrumColorFlag,
+			WinFunc: *spectrumWinFuncFlag,
+			Legend:  *spectrumLegendFlag,
+		},
+		Loudness:       *loudnessFlag,
+		LoudnessTarget: *loudnessTargetFlag,
+		Scopes:         *scopesFlag,
+		FrameInfo:      *frameInfoFlag,
 	})
 	if err != nil {
-		return err
+		return timeoutErr(in, err)
 	}
 
 	if *verboseFlag {
-		verbosef("%s: %s:\n", path, o)
+		verbosef("%s: %s:\n", in.Name, o)
 	}
 
-	for _, im := range o.Images() {
-		if clear {
-			if err := iterm2.ClearScrollback(os.Stderr); err != nil {
-				return err
-			}
-			clear = false
+	// printed when done to not end up in the middle of a redraw
+	var warnings []string
+	for im := range o.Images() {
+		if err := imageFn(im); err != nil {
+			return err
 		}
 
-		if err := iterm2.Image(os.Stdout, im.Image()); err != nil {
-			return err
+		if p, ok := im.(render.Partial); !ok || !p.Partial() {
+			verbosef("%s\n", im)
+			if w, ok := im.(render.Warner); ok && w.Warning() != "" {
+				warnings = append(warnings, w.Warning())
+			}
 		}
-		fmt.Println()
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, w)
+	}
+
+	return timeoutErr(in, o.Err())
+}
 
-		verbosef("%s\n", im)
+func timeoutErr(in render.Input, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s: timeout after %s", in.Name, *timeoutFlag)
 	}
+	return err
+}
 
-	return nil
+func parseSize(s string) (display.Resolution, error) {
+	var w, h int
+	if _, err := fmt.Sscanf(s, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		return display.Resolution{}, fmt.Errorf("invalid size %q, should be WIDTHxHEIGHT", s)
+	}
+	return display.Resolution{Width: w, Height: h, WidthAlign: 1, HeightAlign: 1}, nil
+}
+
+func writeImageFile(path string, m image.Image) error {
+	if path == "-" {
+		return imagefile.Encode(os.Stdout, m, imagefile.PNG)
+	}
+
+	format, err := imagefile.FormatFromPath(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := imagefile.Encode(f, m, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 func main() {
 	flag.Parse()
 
+	// run ffmpeg and ffprobe in own process group that is killed on cancel
+	goffmpeg.CmdSetupFn = procgroup.Set
+	goffmpeg.KillFn = procgroup.Kill
+
+	// cancel on interrupt, renderers kill their child processes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// restore default handler so that a second signal kills even if blocked
+	// somewhere not checking ctx, ex: reading stdin
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	shouldClear := *clearFlag
 
 	if err := func() error {
-		if !iterm2.IsCompatible() {
-			fmt.Fprintln(os.Stdin, "not iterm2 terminal")
+		if *listRenderersFlag {
+			for _, n := range render.Names(all.Renderers) {
+				fmt.Println(n)
+			}
+			return nil
 		}
 
-		r, err := iterm2.PixelResolution(os.Stderr)
-		if err != nil {
+		if *streamsFlag != "" {
+			for _, spec := range strings.Split(*streamsFlag, ",") {
+				if _, err := goffmpeg.ParseStreamSpecifier(spec); err != nil {
+					return err
+				}
+			}
+		}
+
+		if *framesFlag != "" {
+			if _, err := goffmpeg.ParseFrameSelect(*framesFlag); err != nil {
+				return err
+			}
+		}
+
+		switch *audioFlag {
+		case render.AudioWave, render.AudioSpectrum, render.AudioBoth, render.AudioPhase:
+		default:
+			return fmt.Errorf("invalid audio %q, should be wave, spectrum, both or phase", *audioFlag)
+		}
+		switch *spectrumScaleFlag {
+		case "lin", "log":
+		default:
+			return fmt.Errorf("invalid spectrum scale %q, should be lin or log", *spectrumScaleFlag)
+		}
+		if *loudnessTargetFlag < -23 || *loudnessTargetFlag > 0 {
+			return fmt.Errorf("invalid loudness target %d, should be -23 to 0", *loudnessTargetFlag)
+		}
+		if _, err := ffmpeg.ParseScopes(*scopesFlag); err != nil {
 			return err
 		}
 
+		var forceRender render.Render
+		if renderFlag != "auto" {
+			var err error
+			forceRender, err = render.Find(all.Renderers, renderFlag)
+			if err != nil {
+				return err
+			}
+		}
+
+		// write image file if asked to or if output is redirected and not forced
+		writeFile := *writeFlag
+		if writeFile == "" && outputFlag == "auto" && !term.IsTerminal(int(os.Stdout.Fd())) {
+			writeFile = "-"
+		}
+
+		var d display.Display
+		var r display.Resolution
+		var imageFn func(im render.Image) error
+		var images []image.Image
+		if writeFile != "" {
+			var err error
+			r, err = parseSize(*sizeFlag)
+			if err != nil {
+				return err
+			}
+			if writeFile == "-" {
+				logWriter = os.Stderr
+			} else if _, err := imagefile.FormatFromPath(writeFile); err != nil {
+				return err
+			}
+			imageFn = func(im render.Image) error {
+				if p, ok := im.(render.Partial); ok && p.Partial() {
+					return nil
+				}
+				images = append(images, im.Image())
+				return nil
+			}
+		} else {
+			var err error
+			if outputFlag == "auto" {
+				d, err = display.Detect(displayall.Displays, os.Stderr)
+			} else {
+				d, err = display.Find(displayall.Displays, outputFlag)
+			}
+			if err != nil {
+				return err
+			}
+			if td, ok := d.(textart.Display); ok {
+				dither, ok := map[string]textart.Dither{
+					"fs":      textart.FloydSteinberg,
+					"ordered": textart.Ordered,
+					"none":    textart.NoDither,
+				}[*ditherFlag]
+				if !ok {
+					return fmt.Errorf("unknown dither %q", *ditherFlag)
+				}
+				td.Dither = dither
+				d = td
+			}
+
+			r, err = d.PixelResolution(os.Stderr)
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.Name(), err)
+			}
+
+			// rows of last partial image to redraw in place, only if stdout is
+			// a terminal, otherwise only final images are shown
+			redraw := term.IsTerminal(int(os.Stdout.Fd()))
+			redrawRows := 0
+			imageFn = func(im render.Image) error {
+				p, ok := im.(render.Partial)
+				partial := ok && p.Partial()
+				if partial && !redraw {
+					return nil
+				}
+				if shouldClear {
+					if err := d.ClearScrollback(os.Stderr); err != nil {
+						return err
+					}
+					shouldClear = false
+				}
+				if redrawRows > 0 {
+					fmt.Printf("\x1b[%dA\r", redrawRows)
+					redrawRows = 0
+				}
+				if partial {
+					redrawRows = display.Rows(d, im.Image(), r)
+				}
+				if ad, ok := d.(display.Animator); ok {
+					if ai, ok := im.(render.Animation); ok {
+						if err := ad.Animation(os.Stdout, ai.GIF(), r); err != nil {
+							return err
+						}
+						fmt.Println()
+						return nil
+					}
+				}
+				if err := d.Image(os.Stdout, im.Image(), r); err != nil {
+					return err
+				}
+				fmt.Println()
+				return nil
+			}
+		}
+
 		files := flag.Args()
 		if len(files) == 0 {
+			files = []string{"-"}
+		}
+
+		if *interactiveFlag {
+			if d == nil {
+				return fmt.Errorf("interactive mode needs a terminal")
+			}
+			if files[0] != "-" {
+				return interactive(ctx, d, r, files[0])
+			}
+			// seeking needs a file
 			f, err := os.CreateTemp("", "ffcat")
 			if err != nil {
 				return err
@@ -171,17 +441,31 @@ func main() {
 				return err
 			}
 			f.Close()
-			files = append(files, f.Name())
+			return interactive(ctx, d, r, f.Name())
 		}
 
 		for _, a := range files {
-			if err := previewFile(r, a, shouldClear); err != nil {
+			if err := previewFile(ctx, forceRender, r, a, imageFn); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
 				fmt.Fprintln(os.Stderr, err)
 			}
-			shouldClear = false
 		}
+
+		if writeFile != "" {
+			if len(images) == 0 {
+				return fmt.Errorf("no images to write")
+			}
+			return writeImageFile(writeFile, imagefile.Stack(images))
+		}
+
 		return nil
 	}(); err != nil {
+		if ctx.Err() != nil {
+			// 128 + SIGINT like a shell
+			os.Exit(130)
+		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}